@@ -205,6 +205,16 @@ func (b *Buffer) Truncate(n int) {
 	b.pos -= n
 }
 
+// Moves the write position back to pos (which must be <= Len) and clears
+// any write error (e.g. ErrMaxSize). Unlike Reset, the data before pos
+// is kept, so a buffer can be rewound to a shared prefix after a write
+// overflowed.
+func (b *Buffer) Rewind(pos int) {
+	b.pos = pos
+	b.read = 0
+	b.err = nil
+}
+
 func (b *Buffer) Seek(offset int64, whence int) (int64, error) {
 	var target int64
 	switch whence {
@@ -190,6 +190,20 @@ func Test_Buffer_Truncate(t *testing.T) {
 	assert.Equal(t, testMustString(b), "")
 }
 
+func Test_Buffer_Rewind(t *testing.T) {
+	b := New(4, 8)
+	b.Write([]byte("1234"))
+	b.Write([]byte("56789"))
+	assert.Equal(t, b.Error(), ErrMaxSize)
+
+	b.Rewind(2)
+	assert.Nil(t, b.Error())
+	assert.Equal(t, testMustString(b), "12")
+
+	b.Write([]byte("ab"))
+	assert.Equal(t, testMustString(b), "12ab")
+}
+
 func Test_Buffer_SqliteBytes(t *testing.T) {
 	b := New(5, 20)
 	b.Write([]byte("up"))
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"src.goblgobl.com/utils/log"
+)
+
+// All of the configured predicates must match for an entry to match.
+// An unconfigured predicate always matches.
+type Filter struct {
+	// _l values (info, warn, error, fatal, req)
+	levels []string
+
+	// _c of request entries, or the "route" field of other entries
+	routes []string
+
+	// exact status ("404") or class of status ("5xx")
+	statuses []string
+
+	// ms must be greater than this
+	minMs float64
+	hasMs bool
+
+	// key=value pairs that must all be present
+	where []keyValue
+}
+
+type keyValue struct {
+	key   string
+	value string
+}
+
+func (f *Filter) Levels(levels string) {
+	f.levels = splitList(levels, true)
+}
+
+func (f *Filter) Routes(routes string) {
+	f.routes = splitList(routes, false)
+}
+
+func (f *Filter) Statuses(statuses string) error {
+	f.statuses = splitList(statuses, true)
+	for _, status := range f.statuses {
+		if len(status) != 3 {
+			return errors.New("invalid status: " + status + " (expected something like 404 or 5xx)")
+		}
+	}
+	return nil
+}
+
+func (f *Filter) MinMs(ms float64) {
+	f.minMs = ms
+	f.hasMs = true
+}
+
+func (f *Filter) Where(pair string) error {
+	key, value, ok := strings.Cut(pair, "=")
+	if !ok || key == "" {
+		return errors.New("invalid where: " + pair + " (expected key=value)")
+	}
+	f.where = append(f.where, keyValue{key: key, value: value})
+	return nil
+}
+
+func (f *Filter) Match(entry *log.KvEntry) bool {
+	if len(f.levels) > 0 && !matchAny(entry, "_l", f.levels) {
+		return false
+	}
+
+	if len(f.routes) > 0 {
+		key := "route"
+		if level, _ := entry.Get("_l"); string(level) == "req" {
+			key = "_c"
+		}
+		if !matchAny(entry, key, f.routes) {
+			return false
+		}
+	}
+
+	if len(f.statuses) > 0 && !f.matchStatus(entry) {
+		return false
+	}
+
+	if f.hasMs {
+		value, ok := entry.Get("ms")
+		if !ok {
+			return false
+		}
+		ms, err := strconv.ParseFloat(string(value), 64)
+		if err != nil || ms <= f.minMs {
+			return false
+		}
+	}
+
+	for _, kv := range f.where {
+		value, ok := entry.Get(kv.key)
+		if !ok || string(value) != kv.value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f *Filter) matchStatus(entry *log.KvEntry) bool {
+	value, ok := entry.Get("status")
+	if !ok || len(value) != 3 {
+		return false
+	}
+
+	for _, status := range f.statuses {
+		if status[0] == value[0] && (status[1:] == "xx" || status[1:] == string(value[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(entry *log.KvEntry, key string, values []string) bool {
+	value, ok := entry.Get(key)
+	if !ok {
+		return false
+	}
+	for _, v := range values {
+		if string(value) == v {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(list string, lower bool) []string {
+	if list == "" {
+		return nil
+	}
+	if lower {
+		list = strings.ToLower(list)
+	}
+	values := strings.Split(list, ",")
+	for i, value := range values {
+		values[i] = strings.TrimSpace(value)
+	}
+	return values
+}
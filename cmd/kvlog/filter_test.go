@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+const testLog = `_l=info _t=1 _c=log_config level=INFO
+_l=req _t=2 _c=users_show status=200 ms=12 res=99
+_l=req _t=3 _c=users_show status=500 ms=300 res=95 _err="oh no"
+_l=req _t=4 _c=users_list status=404 ms=400 pid=p1
+not a valid line
+_l=error _t=5 _c=handler route=users_list status=500 _err="a \"b\""
+`
+
+func Test_Filter_NoPredicates(t *testing.T) {
+	out, warn := testRun(t, new(Filter), false)
+	assert.Equal(t, len(out), 5)
+	assert.Equal(t, warn, "kvlog: test: kv line 5 at offset 3 - missing '=' after key\n")
+}
+
+func Test_Filter_Levels(t *testing.T) {
+	f := new(Filter)
+	f.Levels("ERROR, info")
+	out, _ := testRun(t, f, false)
+	assert.Equal(t, len(out), 2)
+	assert.StringContains(t, out[0], "_t=1")
+	assert.StringContains(t, out[1], "_t=5")
+}
+
+func Test_Filter_Routes(t *testing.T) {
+	f := new(Filter)
+	f.Routes("users_list")
+	out, _ := testRun(t, f, false)
+	assert.Equal(t, len(out), 2)
+	assert.StringContains(t, out[0], "_t=4")
+	assert.StringContains(t, out[1], "_t=5")
+}
+
+func Test_Filter_Statuses(t *testing.T) {
+	f := new(Filter)
+	assert.Nil(t, f.Statuses("5xx,404"))
+	out, _ := testRun(t, f, false)
+	assert.Equal(t, len(out), 3)
+
+	f = new(Filter)
+	assert.Nil(t, f.Statuses("200"))
+	out, _ = testRun(t, f, false)
+	assert.Equal(t, len(out), 1)
+	assert.StringContains(t, out[0], "_t=2")
+
+	assert.Equal(t, f.Statuses("5x").Error(), "invalid status: 5x (expected something like 404 or 5xx)")
+}
+
+func Test_Filter_Ms(t *testing.T) {
+	f := new(Filter)
+	f.MinMs(300)
+	out, _ := testRun(t, f, false)
+	assert.Equal(t, len(out), 1)
+	assert.StringContains(t, out[0], "_t=4")
+
+	f.MinMs(299.5)
+	out, _ = testRun(t, f, false)
+	assert.Equal(t, len(out), 2)
+}
+
+func Test_Filter_Where(t *testing.T) {
+	f := new(Filter)
+	assert.Nil(t, f.Where("pid=p1"))
+	out, _ := testRun(t, f, false)
+	assert.Equal(t, len(out), 1)
+	assert.StringContains(t, out[0], "_t=4")
+
+	assert.Equal(t, f.Where("nope").Error(), "invalid where: nope (expected key=value)")
+}
+
+func Test_Filter_Combined_Json(t *testing.T) {
+	f := new(Filter)
+	f.Levels("req")
+	f.Routes("users_show")
+	f.MinMs(100)
+	out, _ := testRun(t, f, true)
+	assert.Equal(t, len(out), 1)
+	assert.Equal(t, out[0], `{"_l":"req","_t":"3","_c":"users_show","status":"500","ms":"300","res":"95","_err":"oh no"}`)
+
+	f = new(Filter)
+	f.Levels("error")
+	out, _ = testRun(t, f, true)
+	assert.Equal(t, out[0], `{"_l":"error","_t":"5","_c":"handler","route":"users_list","status":"500","_err":"a \"b\""}`)
+}
+
+func testRun(t *testing.T, filter *Filter, asJson bool) ([]string, string) {
+	t.Helper()
+	out := &strings.Builder{}
+	warn := &strings.Builder{}
+	err := run(strings.NewReader(testLog), "test", filter, asJson, 1024, out, warn)
+	assert.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		lines = nil
+	}
+	return lines, warn.String()
+}
@@ -0,0 +1,124 @@
+// Filters KV log files (as written by log.KvLogger) and optionally converts
+// the matching lines to JSON.
+//
+//	kvlog -level error,fatal app.log
+//	kvlog -level req -route users_show -status 5xx -ms 250 -json app.log
+//	cat app.log | kvlog -where pid=8f3a
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"src.goblgobl.com/utils/buffer"
+	"src.goblgobl.com/utils/log"
+)
+
+type whereFlag struct {
+	filter *Filter
+}
+
+func (w whereFlag) String() string {
+	return ""
+}
+
+func (w whereFlag) Set(value string) error {
+	return w.filter.Where(value)
+}
+
+func main() {
+	filter := new(Filter)
+
+	flags := flag.NewFlagSet("kvlog", flag.ExitOnError)
+	levels := flags.String("level", "", "comma-separated levels to include (info, warn, error, fatal, req)")
+	routes := flags.String("route", "", "comma-separated routes to include")
+	statuses := flags.String("status", "", "comma-separated statuses to include (e.g. 404 or 5xx)")
+	ms := flags.Float64("ms", -1, "only include entries with an ms greater than this")
+	asJson := flags.Bool("json", false, "output matching entries as JSON")
+	maxLine := flags.Int("max_line", 1048576, "maximum line size, in bytes")
+	flags.Var(whereFlag{filter}, "where", "key=value that must be present (can be repeated)")
+	flags.Parse(os.Args[1:])
+
+	filter.Levels(*levels)
+	filter.Routes(*routes)
+	if err := filter.Statuses(*statuses); err != nil {
+		fatal(err)
+	}
+	if *ms >= 0 {
+		filter.MinMs(*ms)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	failed := false
+	for _, path := range paths {
+		if err := process(path, filter, *asJson, *maxLine, out); err != nil {
+			fmt.Fprintf(os.Stderr, "kvlog: %s: %v\n", path, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		out.Flush()
+		os.Exit(1)
+	}
+}
+
+func process(path string, filter *Filter, asJson bool, maxLine int, out io.Writer) error {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	return run(in, path, filter, asJson, maxLine, out, os.Stderr)
+}
+
+func run(in io.Reader, name string, filter *Filter, asJson bool, maxLine int, out io.Writer, warn io.Writer) error {
+	var jsonBuffer *buffer.Buffer
+	if asJson {
+		// worst case, every byte of a line is escaped as \u00XX
+		jsonBuffer = buffer.New(65536, uint32(maxLine*6+2))
+	}
+
+	scanner := log.NewKvScanner(in, maxLine)
+	for scanner.Scan() {
+		entry, err := scanner.Entry()
+		if err != nil {
+			fmt.Fprintf(warn, "kvlog: %s: %v\n", name, err)
+			continue
+		}
+
+		if !filter.Match(entry) {
+			continue
+		}
+
+		if asJson {
+			jsonBuffer.Reset()
+			entry.WriteJSON(jsonBuffer)
+			jsonBuffer.WriteByte('\n')
+			out.Write(jsonBuffer.OKBytes())
+		} else {
+			out.Write(scanner.Bytes())
+			out.Write([]byte{'\n'})
+		}
+	}
+	return scanner.Err()
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "kvlog:", err)
+	os.Exit(2)
+}
@@ -27,10 +27,27 @@ const (
 	RES_SERIALIZATION_ERROR  = 2002
 	RES_INVALID_JSON_PAYLOAD = 2003
 	RES_VALIDATION           = 2004
+	RES_BODY_TOO_LARGE       = 2005
+	RES_UNAUTHORIZED         = 2006
+	RES_TIMEOUT              = 2007
+	RES_NOT_FOUND            = 2008
+	RES_METHOD_NOT_ALLOWED   = 2009
+	RES_RATE_LIMITED         = 2010
+	RES_NOT_READY            = 2011
 
 	ERR_INVALID_LOG_LEVEL  = 3001
 	ERR_INVALID_LOG_FORMAT = 3002
 	ERR_PG_INIT            = 3003
 	ERR_SQLITE_INIT        = 3004
 	// ERR_BUFFER_CAPACITY_MAX = 3005 // reserved
+	ERR_INVALID_LOG_OVERFLOW    = 3006
+	ERR_INVALID_LOG_TIME_FORMAT = 3007
+	ERR_INVALID_LOG_REDACTION   = 3008
+	ERR_INVALID_LOG_SINK        = 3009
+	ERR_HTTP_LISTEN             = 3010
+	ERR_HTTP_SERVE              = 3011
+	ERR_HTTP_SHUTDOWN           = 3012
+	ERR_HEALTH_TIMEOUT          = 3013
+	ERR_POOL_DEPLETION          = 3014
+	ERR_SQLITE_HEALTH_BUSY      = 3015
 )
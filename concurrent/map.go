@@ -5,6 +5,7 @@ A concurrent-safe string => generic map
 */
 
 import (
+	"context"
 	"sync"
 
 	"golang.org/x/sync/singleflight"
@@ -12,7 +13,18 @@ import (
 
 type Loader[V any] func(id string) (V, error)
 
+// A loader which receives the context given to GetContext (or
+// context.Background() when Get is used). This lets the loader use
+// request-scoped values, such as the request's logger (see log.FromContext).
+type ContextLoader[V any] func(ctx context.Context, id string) (V, error)
+
 func NewMap[V any](loader Loader[V], cleaner func(v V)) Map[V] {
+	return NewContextMap(func(_ context.Context, id string) (V, error) {
+		return loader(id)
+	}, cleaner)
+}
+
+func NewContextMap[V any](loader ContextLoader[V], cleaner func(v V)) Map[V] {
 	shards := make([]*shard[V], 64)
 	for i := 0; i < len(shards); i++ {
 		shards[i] = &shard[V]{
@@ -31,7 +43,13 @@ type Map[V any] struct {
 }
 
 func (m Map[V]) Get(id string) (V, error) {
-	return m.shard(id).get(id)
+	return m.shard(id).get(context.Background(), id)
+}
+
+// Concurrent loads of the same id are collapsed into a single call to the
+// loader, which receives the ctx of the first caller.
+func (m Map[V]) GetContext(ctx context.Context, id string) (V, error) {
+	return m.shard(id).get(ctx, id)
 }
 
 func (m Map[V]) Put(id string, value V) {
@@ -50,12 +68,12 @@ func (m Map[V]) shard(id string) *shard[V] {
 type shard[V any] struct {
 	sf      *singleflight.Group
 	cleaner func(v V)
-	loader  Loader[V]
+	loader  ContextLoader[V]
 	lookup  map[string]V
 	sync.RWMutex
 }
 
-func (s *shard[V]) get(id string) (V, error) {
+func (s *shard[V]) get(ctx context.Context, id string) (V, error) {
 	s.RLock()
 	value, exists := s.lookup[id]
 	s.RUnlock()
@@ -65,7 +83,7 @@ func (s *shard[V]) get(id string) (V, error) {
 	}
 
 	ivalue, err, _ := s.sf.Do(id, func() (interface{}, error) {
-		value, err := s.loader(id)
+		value, err := s.loader(ctx, id)
 		if err != nil {
 			var dflt V
 			return dflt, err
@@ -1,6 +1,7 @@
 package concurrent
 
 import (
+	"context"
 	"errors"
 	"strconv"
 	"testing"
@@ -12,6 +13,22 @@ type TestItem struct {
 	id string
 }
 
+func Test_GetContext_Loader(t *testing.T) {
+	type key struct{}
+	m := NewContextMap[*TestItem](func(ctx context.Context, id string) (*TestItem, error) {
+		prefix, _ := ctx.Value(key{}).(string)
+		return &TestItem{id: prefix + id}, nil
+	}, nil)
+
+	i, err := m.GetContext(context.WithValue(context.Background(), key{}, "ctx-"), "a")
+	assert.Nil(t, err)
+	assert.Equal(t, i.id, "ctx-a")
+
+	i, err = m.Get("b")
+	assert.Nil(t, err)
+	assert.Equal(t, i.id, "b")
+}
+
 func Test_Get_Loader(t *testing.T) {
 	m := NewMap[*TestItem](func(id string) (*TestItem, error) {
 		if id == "nope" {
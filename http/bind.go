@@ -0,0 +1,81 @@
+package http
+
+/*
+Parses and validates request input in a single call. On success, the
+validated input is returned (validators write normalized values, such as
+defaults, back into it). Otherwise, a ready-to-return Response is:
+
+	input, res := http.BindJSON(conn, validationPool, createValidator, env)
+	if res != nil {
+		return res, nil
+	}
+
+The validation context is checked out of the pool and released before
+returning.
+*/
+
+import (
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils/typed"
+	"src.goblgobl.com/utils/validation"
+)
+
+// Parses the request body as a JSON object and validates it. An empty body
+// is validated as an empty object. A body that isn't a JSON object results in
+// InvalidJSON.
+func BindJSON[T any](conn *fasthttp.RequestCtx, pool validation.Pool[T], validator *validation.ObjectValidator[T], env T) (typed.Typed, Response) {
+	var input typed.Typed
+	if body := conn.PostBody(); len(body) > 0 {
+		var err error
+		if input, err = typed.Json(body); err != nil {
+			return nil, InvalidJSON
+		}
+	}
+	if input == nil {
+		input = typed.Typed{}
+	}
+	return bind(input, pool, validator, env)
+}
+
+// Validates the request's query string (see QueryToTyped). Values are
+// converted to the types the validator's fields expect (see
+// validation.ObjectValidator.ConvertStrings), so ?active=true satisfies a
+// Bool field and ?tags=a an Array field.
+func BindQuery[T any](conn *fasthttp.RequestCtx, pool validation.Pool[T], validator *validation.ObjectValidator[T], env T) (typed.Typed, Response) {
+	input := QueryToTyped(conn.QueryArgs())
+	validator.ConvertStrings(input)
+	return bind(input, pool, validator, env)
+}
+
+func bind[T any](input typed.Typed, pool validation.Pool[T], validator *validation.ObjectValidator[T], env T) (typed.Typed, Response) {
+	ctx := pool.Checkout(env)
+	defer ctx.Release()
+
+	if !validator.ValidateInput(input, ctx) {
+		// Validation serializes the errors immediately, so the context can
+		// be released
+		return nil, Validation(ctx)
+	}
+	return input, nil
+}
+
+// Converts query string arguments into a typed.Typed. Values are strings
+// (which numeric validators accept). A key which appears more than once
+// becomes an []any of its values. BindQuery further converts these based
+// on the validator.
+func QueryToTyped(args *fasthttp.Args) typed.Typed {
+	t := make(typed.Typed, args.Len())
+	args.VisitAll(func(key []byte, value []byte) {
+		k := string(key)
+		v := string(value)
+		switch existing := t[k].(type) {
+		case nil:
+			t[k] = v
+		case []any:
+			t[k] = append(existing, v)
+		default:
+			t[k] = []any{existing, v}
+		}
+	})
+	return t
+}
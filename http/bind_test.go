@@ -0,0 +1,130 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
+	"src.goblgobl.com/utils/typed"
+	"src.goblgobl.com/utils/validation"
+)
+
+var (
+	bindPool      = validation.NewPool[string](2, 5)
+	bindValidator = validation.Object[string]().
+			Field("name", validation.String[string]().Required()).
+			Field("age", validation.Int[string]().Min(18).Default(21)).
+			Field("tags", validation.Array[string]().Validator(validation.String[string]())).
+			Field("active", validation.Bool[string]()).
+			Field("price", validation.Float[string]())
+)
+
+func Test_BindJSON_InvalidJSON(t *testing.T) {
+	for _, body := range []string{"{", "[1, 2]", "123"} {
+		conn := &fasthttp.RequestCtx{}
+		conn.Request.SetBodyString(body)
+		input, res := BindJSON(conn, bindPool, bindValidator, "env")
+		assert.Nil(t, input)
+		assert.Equal(t, res.(StaticResponse).status, 400)
+	}
+}
+
+func Test_BindJSON_Invalid(t *testing.T) {
+	for _, body := range []string{"", "null", `{"age": 3}`} {
+		conn := &fasthttp.RequestCtx{}
+		conn.Request.SetBodyString(body)
+		input, res := BindJSON(conn, bindPool, bindValidator, "env")
+		assert.Nil(t, input)
+
+		res.Write(conn, log.Noop{})
+		assert.Equal(t, conn.Response.StatusCode(), 400)
+		assertCode(t, conn, 2004)
+		invalid := typed.Must(conn.Response.Body()).Objects("invalid")
+		assert.Equal(t, invalid[0].String("field"), "name")
+	}
+}
+
+func Test_BindJSON_Valid(t *testing.T) {
+	var envs []string
+	validator := validation.Object[string]().
+		Field("name", validation.String[string]().Required().Func(func(value string, ctx *validation.Context[string]) any {
+			envs = append(envs, ctx.Env)
+			return value
+		})).
+		Field("age", validation.Int[string]().Default(21))
+
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.SetBodyString(`{"name": "leto"}`)
+	input, res := BindJSON(conn, bindPool, validator, "env1")
+	assert.Nil(t, res)
+	assert.Equal(t, input.String("name"), "leto")
+	assert.Equal(t, input.Int("age"), 21)
+	assert.List(t, envs, []string{"env1"})
+}
+
+func Test_BindQuery(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.SetRequestURI("/users?name=ghanima&age=19&tags=a&tags=b")
+	input, res := BindQuery(conn, bindPool, bindValidator, "env")
+	assert.Nil(t, res)
+	assert.Equal(t, input.String("name"), "ghanima")
+	assert.Equal(t, input.Int("age"), 19)
+	assert.List(t, input.Strings("tags"), []string{"a", "b"})
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.SetRequestURI("/users?age=old")
+	input, res = BindQuery(conn, bindPool, bindValidator, "env")
+	assert.Nil(t, input)
+	res.Write(conn, log.Noop{})
+	assertCode(t, conn, 2004)
+	invalid := typed.Must(conn.Response.Body()).Objects("invalid")
+	assert.Equal(t, len(invalid), 2)
+	assert.Equal(t, invalid[1].String("field"), "age")
+}
+
+func Test_BindQuery_Conversion(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.SetRequestURI("/users?name=ghanima&tags=a&active=true&price=1.5&age=30")
+	input, res := BindQuery(conn, bindPool, bindValidator, "env")
+	assert.Nil(t, res)
+	assert.List(t, input.Strings("tags"), []string{"a"})
+	assert.Equal(t, input.Bool("active"), true)
+	assert.Equal(t, input.Float("price"), 1.5)
+	assert.Equal(t, input.Int("age"), 30)
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.SetRequestURI("/users?name=ghanima&active=false")
+	input, res = BindQuery(conn, bindPool, bindValidator, "env")
+	assert.Nil(t, res)
+	assert.Equal(t, input.Bool("active"), false)
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.SetRequestURI("/users?name=ghanima&active=maybe")
+	_, res = BindQuery(conn, bindPool, bindValidator, "env")
+	res.Write(conn, log.Noop{})
+	invalid := typed.Must(conn.Response.Body()).Objects("invalid")
+	assert.Equal(t, len(invalid), 1)
+	assert.Equal(t, invalid[0].String("field"), "active")
+	assert.Equal(t, invalid[0].Int("code"), 1009)
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.SetRequestURI("/users?name=ghanima&price=cheap")
+	_, res = BindQuery(conn, bindPool, bindValidator, "env")
+	res.Write(conn, log.Noop{})
+	invalid = typed.Must(conn.Response.Body()).Objects("invalid")
+	assert.Equal(t, len(invalid), 1)
+	assert.Equal(t, invalid[0].String("field"), "price")
+	assert.Equal(t, invalid[0].Int("code"), 1016)
+}
+
+func Test_QueryToTyped(t *testing.T) {
+	args := &fasthttp.Args{}
+	assert.Equal(t, len(QueryToTyped(args)), 0)
+
+	args.Parse("a=1&b=&c=x&c=y&c=z")
+	q := QueryToTyped(args)
+	assert.Equal(t, q.String("a"), "1")
+	assert.Equal(t, q.String("b"), "")
+	assert.List(t, q.Strings("c"), []string{"x", "y", "z"})
+}
@@ -0,0 +1,168 @@
+package http
+
+/*
+HTTP caching for JSON responses. With Caching.ETag, a strong ETag is
+generated from a hash of the body; when the request's If-None-Match matches
+it, a 304 with no body is sent instead. Similarly, with LastModified, a
+request with an If-Modified-Since no older than it (and no If-None-Match)
+gets a 304.
+
+A strong ETag identifies the exact bytes sent, so when the body is
+compressed (see EnableCompression), the ETag is sent as a weak one instead.
+Like any response when compression is enabled, 304s vary on Accept-Encoding.
+
+304s are logged with status=304 and cached (and res=0), so that cache
+hit rates can be measured.
+*/
+
+import (
+	"bytes"
+	"encoding/hex"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils/log"
+)
+
+const NoCache = "no-cache"
+
+var (
+	NotModifiedLogData = log.NewField().
+				Int("status", 304).
+				Int("res", 0).
+				Finalize()
+
+	etagHeader         = []byte("ETag")
+	cacheControlHeader = []byte("Cache-Control")
+	ifNoneMatchHeader  = []byte("If-None-Match")
+	weakETagPrefix     = []byte("W/")
+)
+
+type Caching struct {
+	// Generate a strong ETag from the body and honor If-None-Match
+	ETag bool
+
+	// The Cache-Control header (see PublicMaxAge and PrivateMaxAge)
+	CacheControl string
+
+	// The Last-Modified header (ignored if zero). Honors If-Modified-Since.
+	LastModified time.Time
+}
+
+// Cache-Control value allowing any cache to store the response for d
+func PublicMaxAge(d time.Duration) string {
+	return "public, max-age=" + strconv.Itoa(int(d.Seconds()))
+}
+
+// Cache-Control value allowing only the client to store the response for d
+func PrivateMaxAge(d time.Duration) string {
+	return "private, max-age=" + strconv.Itoa(int(d.Seconds()))
+}
+
+func OKCached(data any, caching Caching) Response {
+	res := NewJSONResponse(data, 200, OKLogData)
+	if r, ok := res.(JSONResponse); ok {
+		r.Caching = &caching
+		return r
+	}
+	// a SerializationError
+	return res
+}
+
+func OKBytesCached(body []byte, caching Caching) Response {
+	return JSONResponse{
+		Status:  200,
+		Body:    body,
+		LogData: OKLogData,
+		Caching: &caching,
+	}
+}
+
+// A strong ETag ("16 hex chars") of the body
+func ETag(body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+
+	var sum [8]byte
+	var buf [18]byte
+	buf[0] = '"'
+	hex.Encode(buf[1:17], h.Sum(sum[:0]))
+	buf[17] = '"'
+	return string(buf[:])
+}
+
+// Sets the caching headers, except for the ETag, which depends on whether
+// the body ends up compressed (see setETag). Returns the ETag (empty when
+// not enabled) and whether the client's copy is current, in which case the
+// response should be a 304 (see notModified).
+func (c *Caching) write(conn *fasthttp.RequestCtx, body []byte) (string, bool) {
+	header := &conn.Response.Header
+	if cc := c.CacheControl; cc != "" {
+		header.SetBytesK(cacheControlHeader, cc)
+	}
+
+	lastModified := c.LastModified
+	if !lastModified.IsZero() {
+		header.SetLastModified(lastModified)
+	}
+
+	var etag string
+	if c.ETag {
+		etag = ETag(body)
+	}
+
+	// conditional requests only apply to GET and HEAD
+	if !conn.IsGet() && !conn.IsHead() {
+		return etag, false
+	}
+
+	ifNoneMatch := conn.Request.Header.PeekBytes(ifNoneMatchHeader)
+	if etag != "" && len(ifNoneMatch) > 0 {
+		return etag, etagMatches(ifNoneMatch, etag)
+	}
+
+	if len(ifNoneMatch) == 0 && !lastModified.IsZero() {
+		return etag, !conn.IfModifiedSince(lastModified)
+	}
+	return etag, false
+}
+
+// Sends the (strong) etag as a weak one when the body is compressed, since
+// the same ETag is sent for each of the body's content-codings
+func setETag(conn *fasthttp.RequestCtx, etag string, compressed bool) {
+	if compressed {
+		etag = "W/" + etag
+	}
+	conn.Response.Header.SetBytesK(etagHeader, etag)
+}
+
+// If-None-Match uses the weak comparison, so W/"x" matches "x"
+func etagMatches(ifNoneMatch []byte, etag string) bool {
+	if bytes.Equal(bytes.TrimSpace(ifNoneMatch), []byte("*")) {
+		return true
+	}
+	for len(ifNoneMatch) > 0 {
+		var candidate []byte
+		candidate, ifNoneMatch, _ = bytes.Cut(ifNoneMatch, []byte{','})
+		candidate = bytes.TrimPrefix(bytes.TrimSpace(candidate), weakETagPrefix)
+		if string(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// l is the length of the body which isn't being sent. The ETag (if any) is
+// the one a 200 would have been sent with.
+func notModified(conn *fasthttp.RequestCtx, logger log.Logger, etag string, l int) log.Logger {
+	// also adds the Vary header, when compression is enabled
+	encoding := compression.responseEncoding(conn, l)
+	if etag != "" {
+		setETag(conn, etag, encoding != EncodingIdentity)
+	}
+	conn.SetStatusCode(304)
+	conn.ResetBody()
+	return logger.Field(NotModifiedLogData).Bool("cached", true)
+}
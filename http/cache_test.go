@@ -0,0 +1,163 @@
+package http
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
+)
+
+func Test_ETag(t *testing.T) {
+	etag := ETag([]byte(`{"over":9000}`))
+	assert.Equal(t, len(etag), 18)
+	assert.Equal(t, etag[0], '"')
+	assert.Equal(t, etag[17], '"')
+	assert.Equal(t, ETag([]byte(`{"over":9000}`)), etag)
+	assert.NotEqual(t, ETag([]byte(`{"over":9001}`)), etag)
+}
+
+func Test_CacheControl_Helpers(t *testing.T) {
+	assert.Equal(t, PublicMaxAge(time.Hour), "public, max-age=3600")
+	assert.Equal(t, PrivateMaxAge(90*time.Second), "private, max-age=90")
+}
+
+func Test_OKCached_Headers(t *testing.T) {
+	lastModified := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	res := OKCached(map[string]any{"over": 9000}, Caching{
+		ETag:         true,
+		CacheControl: PrivateMaxAge(time.Minute),
+		LastModified: lastModified,
+	})
+
+	conn := &fasthttp.RequestCtx{}
+	logged := writeCached(res, conn)
+
+	header := &conn.Response.Header
+	assert.Equal(t, conn.Response.StatusCode(), 200)
+	assert.Equal(t, string(conn.Response.Body()), `{"over":9000}`)
+	assert.Equal(t, string(header.Peek("ETag")), ETag([]byte(`{"over":9000}`)))
+	assert.Equal(t, string(header.Peek("Cache-Control")), "private, max-age=60")
+	assert.Equal(t, string(header.Peek("Last-Modified")), "Wed, 05 Apr 2023 06:07:08 GMT")
+	assert.Equal(t, logged["status"], "200")
+	assert.Equal(t, logged["res"], "13")
+	_, exists := logged["cached"]
+	assert.False(t, exists)
+}
+
+func Test_OKCached_IfNoneMatch(t *testing.T) {
+	body := []byte(`{"over":9000}`)
+	etag := ETag(body)
+	res := OKBytesCached(body, Caching{ETag: true, CacheControl: NoCache})
+
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"nope", ` + etag, "*"} {
+		conn := &fasthttp.RequestCtx{}
+		conn.Request.Header.Set("If-None-Match", ifNoneMatch)
+		logged := writeCached(res, conn)
+
+		assert.Equal(t, conn.Response.StatusCode(), 304)
+		assert.Equal(t, len(conn.Response.Body()), 0)
+		assert.Equal(t, string(conn.Response.Header.Peek("ETag")), etag)
+		assert.Equal(t, string(conn.Response.Header.Peek("Cache-Control")), "no-cache")
+		assert.Equal(t, logged["status"], "304")
+		assert.Equal(t, logged["cached"], "Y")
+		assert.Equal(t, logged["res"], "0")
+	}
+
+	for _, ifNoneMatch := range []string{`"nope"`, `W/"nope", "other"`} {
+		conn := &fasthttp.RequestCtx{}
+		conn.Request.Header.Set("If-None-Match", ifNoneMatch)
+		writeCached(res, conn)
+		assert.Equal(t, conn.Response.StatusCode(), 200)
+		assert.Equal(t, string(conn.Response.Body()), string(body))
+	}
+
+	// only GET and HEAD are conditional
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.SetMethod("POST")
+	conn.Request.Header.Set("If-None-Match", etag)
+	writeCached(res, conn)
+	assert.Equal(t, conn.Response.StatusCode(), 200)
+	assert.Equal(t, string(conn.Response.Header.Peek("ETag")), etag)
+}
+
+func Test_OKCached_Compressed(t *testing.T) {
+	defer enableTestCompression(10)()
+
+	body := []byte(`{"over":"` + strings.Repeat("9", 100) + `"}`)
+	etag := ETag(body)
+	res := OKBytesCached(body, Caching{ETag: true})
+
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("Accept-Encoding", "gzip")
+	writeCached(res, conn)
+	assert.Equal(t, conn.Response.StatusCode(), 200)
+	assert.Equal(t, string(conn.Response.Header.Peek("Content-Encoding")), "gzip")
+	assert.Equal(t, string(conn.Response.Header.Peek("ETag")), "W/"+etag)
+
+	// the uncompressed body keeps the strong ETag
+	conn = &fasthttp.RequestCtx{}
+	writeCached(res, conn)
+	assert.Equal(t, conn.Response.StatusCode(), 200)
+	assert.Equal(t, string(conn.Response.Header.Peek("ETag")), etag)
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("Accept-Encoding", "gzip")
+	conn.Request.Header.Set("If-None-Match", "W/"+etag)
+	writeCached(res, conn)
+	assert.Equal(t, conn.Response.StatusCode(), 304)
+	assert.Equal(t, string(conn.Response.Header.Peek("ETag")), "W/"+etag)
+	assert.Equal(t, string(conn.Response.Header.Peek("Vary")), "Accept-Encoding")
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("If-None-Match", etag)
+	writeCached(res, conn)
+	assert.Equal(t, conn.Response.StatusCode(), 304)
+	assert.Equal(t, string(conn.Response.Header.Peek("ETag")), etag)
+	assert.Equal(t, string(conn.Response.Header.Peek("Vary")), "Accept-Encoding")
+}
+
+func Test_OKCached_IfModifiedSince(t *testing.T) {
+	lastModified := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	res := OKCached(1, Caching{LastModified: lastModified})
+
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("If-Modified-Since", "Wed, 05 Apr 2023 06:07:08 GMT")
+	assert.Equal(t, writeCached(res, conn)["status"], "304")
+	assert.Equal(t, conn.Response.StatusCode(), 304)
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("If-Modified-Since", "Wed, 05 Apr 2023 06:07:07 GMT")
+	writeCached(res, conn)
+	assert.Equal(t, conn.Response.StatusCode(), 200)
+
+	// If-None-Match takes precedence
+	res = OKCached(1, Caching{ETag: true, LastModified: lastModified})
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("If-None-Match", `"nope"`)
+	conn.Request.Header.Set("If-Modified-Since", "Wed, 05 Apr 2023 06:07:08 GMT")
+	writeCached(res, conn)
+	assert.Equal(t, conn.Response.StatusCode(), 200)
+}
+
+func Test_OKCached_SerializationError(t *testing.T) {
+	res := read(OKCached(make(chan bool), Caching{ETag: true}))
+	assert.Equal(t, res.status, 500)
+	assert.Equal(t, res.json.Int("code"), 2002)
+}
+
+func Test_OK_NoCaching(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("If-None-Match", "*")
+	writeCached(OK(1), conn)
+	assert.Equal(t, conn.Response.StatusCode(), 200)
+	assert.Equal(t, len(conn.Response.Header.Peek("ETag")), 0)
+}
+
+func writeCached(res Response, conn *fasthttp.RequestCtx) map[string]string {
+	logger := res.Write(conn, log.Request("test"))
+	defer logger.Release()
+	return log.KvParse(string(logger.Bytes()))
+}
@@ -0,0 +1,261 @@
+package http
+
+/*
+Opt-in response compression, negotiated via Accept-Encoding. Enabled (at
+startup) with EnableCompression.
+
+StaticResponses compress their body into every supported encoding when
+they're created (StaticErrors are typically created at startup, possibly
+before EnableCompression is called). Dynamic responses (JSONResponse,
+BufferedJSONResponse and ErrorIdResponse) compress into a buffer checked
+out of the configured pool, which is released once the body is sent. If
+the compressed body doesn't fit in the buffer, or isn't smaller than the
+original, the original body is sent as-is.
+
+The encoding is picked using the server's order of preference among those
+which the client accepts (with a non-zero q). When compressed, the logged
+res (the original length) is joined by res_enc (the length sent) and enc.
+*/
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils/buffer"
+	"src.goblgobl.com/utils/log"
+)
+
+type Encoding uint8
+
+const (
+	EncodingIdentity Encoding = iota
+	EncodingGzip
+	EncodingBrotli
+	EncodingZstd
+)
+
+var (
+	// Set by EnableCompression. nil when compression is disabled.
+	compression *compressor
+
+	defaultEncodings = []Encoding{EncodingBrotli, EncodingZstd, EncodingGzip}
+
+	acceptEncodingHeader = []byte("Accept-Encoding")
+
+	zstdEncoders = sync.Pool{
+		New: func() any {
+			encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+			return encoder
+		},
+	}
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingGzip:
+		return "gzip"
+	case EncodingBrotli:
+		return "br"
+	case EncodingZstd:
+		return "zstd"
+	}
+	return "identity"
+}
+
+type CompressionConfig struct {
+	// Bodies smaller than this (in bytes) are sent uncompressed
+	MinSize int
+
+	// The encodings which can be used, in order of preference. Defaults to
+	// brotli, zstd then gzip.
+	Encodings []Encoding
+
+	// Buffers which dynamic responses are compressed into. A body which
+	// compresses to more than the pool's max size is sent uncompressed.
+	// Defaults to 64 buffers of 16KB, which can grow to 1MB.
+	Pool buffer.Pool
+}
+
+type compressor struct {
+	minSize   int
+	encodings []Encoding
+	pool      buffer.Pool
+}
+
+// Not thread-safe: should only be called at startup.
+func EnableCompression(config CompressionConfig) {
+	encodings := config.Encodings
+	if len(encodings) == 0 {
+		encodings = defaultEncodings
+	}
+	pool := config.Pool
+	if pool.Pool == nil {
+		pool = buffer.NewPool(64, 16*1024, 1024*1024)
+	}
+	compression = &compressor{
+		minSize:   config.MinSize,
+		encodings: encodings,
+		pool:      pool,
+	}
+}
+
+// Not thread-safe: should only be called at startup (or in tests).
+func DisableCompression() {
+	compression = nil
+}
+
+// The encoding to send a body of length l with, or EncodingIdentity. When
+// compression is enabled, the response varies on Accept-Encoding (whether or
+// not this particular response ends up compressed).
+func (c *compressor) responseEncoding(conn *fasthttp.RequestCtx, l int) Encoding {
+	if c == nil {
+		return EncodingIdentity
+	}
+	conn.Response.Header.AddBytesK(varyHeader, "Accept-Encoding")
+	if l < c.minSize || l == 0 {
+		return EncodingIdentity
+	}
+	return negotiateEncoding(conn.Request.Header.PeekBytes(acceptEncodingHeader), c.encodings)
+}
+
+// Picks the first of encodings (the server's preference) which the
+// Accept-Encoding header allows
+func negotiateEncoding(header []byte, encodings []Encoding) Encoding {
+	if len(header) == 0 {
+		return EncodingIdentity
+	}
+
+	// accepted (and rejected) are indexed by Encoding
+	var accepted, rejected [4]bool
+	wildcard := false
+
+	for len(header) > 0 {
+		var item []byte
+		item, header, _ = bytes.Cut(header, []byte{','})
+		name, params, _ := bytes.Cut(item, []byte{';'})
+		name = bytes.TrimSpace(name)
+
+		ok := !zeroQuality(params)
+		switch {
+		case bytes.EqualFold(name, []byte("gzip")):
+			accepted[EncodingGzip], rejected[EncodingGzip] = ok, !ok
+		case bytes.EqualFold(name, []byte("br")):
+			accepted[EncodingBrotli], rejected[EncodingBrotli] = ok, !ok
+		case bytes.EqualFold(name, []byte("zstd")):
+			accepted[EncodingZstd], rejected[EncodingZstd] = ok, !ok
+		case bytes.Equal(name, []byte("*")):
+			wildcard = ok
+		}
+	}
+
+	for _, encoding := range encodings {
+		if accepted[encoding] || (wildcard && !rejected[encoding]) {
+			return encoding
+		}
+	}
+	return EncodingIdentity
+}
+
+// Whether the parameters of an Accept-Encoding item (e.g. " q=0.5") have
+// a q of 0 (0, 0., 0.0, 0.00 or 0.000)
+func zeroQuality(params []byte) bool {
+	for len(params) > 0 {
+		var param []byte
+		param, params, _ = bytes.Cut(params, []byte{';'})
+		param = bytes.TrimSpace(param)
+		if len(param) < 3 || (param[0] != 'q' && param[0] != 'Q') || param[1] != '=' {
+			continue
+		}
+		value := param[2:]
+		if value[0] != '0' {
+			return false
+		}
+		for _, c := range value[1:] {
+			if c != '.' && c != '0' {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Compresses body into a pooled buffer. Returns nil (and the buffer is
+// released) if the body shouldn't or couldn't be compressed. Otherwise,
+// the Content-Encoding header is set and the caller is responsible for
+// the buffer (typically by giving it to SetBodyStream).
+func compressBody(conn *fasthttp.RequestCtx, body []byte) (*buffer.Buffer, Encoding) {
+	c := compression
+	encoding := c.responseEncoding(conn, len(body))
+	if encoding == EncodingIdentity {
+		return nil, encoding
+	}
+
+	buf := c.pool.Checkout()
+	if err := writeEncoded(buf, encoding, body); err != nil || buf.Len() >= len(body) {
+		buf.Release()
+		return nil, EncodingIdentity
+	}
+
+	conn.Response.Header.SetContentEncoding(encoding.String())
+	return buf, encoding
+}
+
+// Sets the response body (compressed, if possible) and logs its length(s)
+func writeBody(conn *fasthttp.RequestCtx, body []byte, logger log.Logger) log.Logger {
+	logger.Int("res", len(body))
+	if buf, encoding := compressBody(conn, body); buf != nil {
+		l := buf.Len()
+		conn.SetBodyStream(buf, l)
+		return logEncoded(logger, encoding, l)
+	}
+	conn.SetBody(body)
+	return logger
+}
+
+func logEncoded(logger log.Logger, encoding Encoding, l int) log.Logger {
+	return logger.Int("res_enc", l).String("enc", encoding.String())
+}
+
+func writeEncoded(w io.Writer, encoding Encoding, body []byte) error {
+	switch encoding {
+	case EncodingGzip:
+		_, err := fasthttp.WriteGzipLevel(w, body, fasthttp.CompressDefaultCompression)
+		return err
+	case EncodingBrotli:
+		_, err := fasthttp.WriteBrotliLevel(w, body, fasthttp.CompressBrotliDefaultCompression)
+		return err
+	case EncodingZstd:
+		encoder := zstdEncoders.Get().(*zstd.Encoder)
+		defer zstdEncoders.Put(encoder)
+		encoder.Reset(w)
+		if _, err := encoder.Write(body); err != nil {
+			encoder.Close()
+			return err
+		}
+		return encoder.Close()
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// A StaticResponse's body in each encoding (indexed by Encoding), nil
+// where compressing doesn't make the body smaller
+type encodedBodies [4][]byte
+
+func newEncodedBodies(body []byte) encodedBodies {
+	var bodies encodedBodies
+	if len(body) == 0 {
+		return bodies
+	}
+	for _, encoding := range []Encoding{EncodingGzip, EncodingBrotli, EncodingZstd} {
+		var buf bytes.Buffer
+		if writeEncoded(&buf, encoding, body) == nil && buf.Len() < len(body) {
+			bodies[encoding] = buf.Bytes()
+		}
+	}
+	return bodies
+}
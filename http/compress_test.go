@@ -0,0 +1,224 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/buffer"
+	"src.goblgobl.com/utils/log"
+)
+
+func Test_NegotiateEncoding(t *testing.T) {
+	all := []Encoding{EncodingBrotli, EncodingZstd, EncodingGzip}
+	gzipOnly := []Encoding{EncodingGzip}
+
+	for _, test := range []struct {
+		header    string
+		encodings []Encoding
+		expected  Encoding
+	}{
+		{"", all, EncodingIdentity},
+		{"identity", all, EncodingIdentity},
+		{"deflate", all, EncodingIdentity},
+		{"gzip", all, EncodingGzip},
+		{"GZIP", all, EncodingGzip},
+		{"gzip, br", all, EncodingBrotli},
+		{"gzip;q=1.0, br;q=0.1", all, EncodingBrotli},
+		{"gzip, br;q=0", all, EncodingGzip},
+		{"gzip, br; q=0.000", all, EncodingGzip},
+		{"gzip, br;q=0.001", all, EncodingBrotli},
+		{"zstd, gzip", all, EncodingZstd},
+		{"gzip, deflate, br, zstd", gzipOnly, EncodingGzip},
+		{"br, zstd", gzipOnly, EncodingIdentity},
+		{"*", all, EncodingBrotli},
+		{"*;q=0", all, EncodingIdentity},
+		{"br;q=0, *", all, EncodingZstd},
+	} {
+		actual := negotiateEncoding([]byte(test.header), test.encodings)
+		if actual != test.expected {
+			t.Errorf("%q: expected %s, got %s", test.header, test.expected, actual)
+		}
+	}
+}
+
+func Test_Compression_Disabled(t *testing.T) {
+	conn := compressionConn("gzip")
+	OK(map[string]any{"data": strings.Repeat("a", 500)}).Write(conn, log.Noop{})
+	assert.Equal(t, len(conn.Response.Header.ContentEncoding()), 0)
+	assert.Equal(t, len(conn.Response.Header.Peek("Vary")), 0)
+	assert.Equal(t, len(conn.Response.Body()), 511)
+}
+
+func Test_Compression_MinSize(t *testing.T) {
+	defer enableTestCompression(600)()
+
+	conn := compressionConn("gzip")
+	OK(map[string]any{"data": strings.Repeat("a", 500)}).Write(conn, log.Noop{})
+	assert.Equal(t, len(conn.Response.Header.ContentEncoding()), 0)
+	assert.Equal(t, string(conn.Response.Header.Peek("Vary")), "Accept-Encoding")
+	assert.Equal(t, len(conn.Response.Body()), 511)
+}
+
+func Test_Compression_NotAccepted(t *testing.T) {
+	defer enableTestCompression(10)()
+
+	conn := compressionConn("")
+	OK(map[string]any{"data": strings.Repeat("a", 500)}).Write(conn, log.Noop{})
+	assert.Equal(t, len(conn.Response.Header.ContentEncoding()), 0)
+	assert.Equal(t, len(conn.Response.Body()), 511)
+}
+
+func Test_Compression_JSONResponse(t *testing.T) {
+	defer enableTestCompression(10)()
+	expected := `{"data":"` + strings.Repeat("a", 500) + `"}`
+
+	for _, encoding := range []string{"gzip", "br", "zstd"} {
+		conn := compressionConn(encoding)
+		logger := OK(map[string]any{"data": strings.Repeat("a", 500)}).Write(conn, log.Request("test"))
+		logged := log.KvParse(string(logger.Bytes()))
+		logger.Release()
+
+		assert.Equal(t, string(conn.Response.Header.ContentEncoding()), encoding)
+		body := decodeBody(t, encoding, conn.Response.Body())
+		assert.Equal(t, string(body), expected)
+
+		assert.Equal(t, logged["res"], "511")
+		assert.Equal(t, logged["enc"], encoding)
+		assert.Equal(t, logged["res_enc"], strconv.Itoa(len(conn.Response.Body())))
+	}
+}
+
+func Test_Compression_Incompressible(t *testing.T) {
+	defer enableTestCompression(1)()
+
+	// compressing this doesn't make it smaller
+	conn := compressionConn("gzip")
+	OK(1).Write(conn, log.Noop{})
+	assert.Equal(t, len(conn.Response.Header.ContentEncoding()), 0)
+	assert.Equal(t, string(conn.Response.Body()), "1")
+}
+
+func Test_Compression_PoolMaxSize(t *testing.T) {
+	EnableCompression(CompressionConfig{MinSize: 1, Pool: buffer.NewPool(8, 4, 8)})
+	defer DisableCompression()
+
+	// the compressed body doesn't fit in the buffer
+	conn := compressionConn("gzip")
+	OK(map[string]any{"data": strings.Repeat("a", 500)}).Write(conn, log.Noop{})
+	assert.Equal(t, len(conn.Response.Header.ContentEncoding()), 0)
+	assert.Equal(t, len(conn.Response.Body()), 511)
+}
+
+func Test_Compression_DefaultPool(t *testing.T) {
+	EnableCompression(CompressionConfig{MinSize: 10})
+	defer DisableCompression()
+
+	conn := compressionConn("gzip")
+	OKBytes([]byte(strings.Repeat("a", 500))).Write(conn, log.Noop{})
+	assert.Equal(t, string(conn.Response.Header.ContentEncoding()), "gzip")
+	assert.Equal(t, len(decodeBody(t, "gzip", conn.Response.Body())), 500)
+}
+
+func Test_Compression_BufferedJSONResponse(t *testing.T) {
+	defer enableTestCompression(10)()
+	pool := buffer.NewPool(8, 32, 1024)
+
+	conn := compressionConn("gzip")
+	logger := BufferedOK(pool, map[string]any{"data": strings.Repeat("a", 500)}).Write(conn, log.Request("test"))
+	logged := log.KvParse(string(logger.Bytes()))
+	logger.Release()
+
+	// the original buffer was released
+	assert.Equal(t, pool.Len(), 8)
+
+	assert.Equal(t, string(conn.Response.Header.ContentEncoding()), "gzip")
+	assert.Equal(t, len(decodeBody(t, "gzip", conn.Response.Body())), 511)
+	assert.Equal(t, logged["res"], "511")
+	assert.Equal(t, logged["enc"], "gzip")
+}
+
+func Test_Compression_StaticResponse(t *testing.T) {
+	res := StaticError(400, 9001, strings.Repeat("invalid ", 20))
+	assert.True(t, res.encoded[EncodingGzip] != nil)
+	assert.True(t, res.encoded[EncodingBrotli] != nil)
+	assert.True(t, res.encoded[EncodingZstd] != nil)
+
+	// not compressed until enabled
+	conn := compressionConn("br")
+	res.Write(conn, log.Noop{})
+	assert.Equal(t, string(conn.Response.Body()), string(res.body))
+
+	defer enableTestCompression(10)()
+	conn = compressionConn("br")
+	logger := res.Write(conn, log.Request("test"))
+	logged := log.KvParse(string(logger.Bytes()))
+	logger.Release()
+
+	assert.Equal(t, conn.Response.StatusCode(), 400)
+	assert.Equal(t, string(conn.Response.Header.ContentEncoding()), "br")
+	assert.Equal(t, string(decodeBody(t, "br", conn.Response.Body())), string(res.body))
+	assert.Equal(t, logged["res"], strconv.Itoa(len(res.body)))
+	assert.Equal(t, logged["res_enc"], strconv.Itoa(len(res.encoded[EncodingBrotli])))
+	assert.Equal(t, logged["enc"], "br")
+
+	// too small to be worth compressing
+	small := StaticError(400, 1, "x")
+	assert.True(t, small.encoded[EncodingGzip] == nil)
+	conn = compressionConn("gzip")
+	small.Write(conn, log.Noop{})
+	assert.Equal(t, string(conn.Response.Body()), string(small.body))
+}
+
+func Test_Compression_ErrorIdResponse(t *testing.T) {
+	EnableCompression(CompressionConfig{MinSize: 10, Pool: buffer.NewPool(8, 32, 1024)})
+	defer DisableCompression()
+
+	conn := compressionConn("zstd")
+	ServerError(fakeErr(strings.Repeat("fail ", 100)), true).Write(conn, log.Noop{})
+	assert.Equal(t, conn.Response.StatusCode(), 500)
+	assert.Equal(t, string(conn.Response.Header.ContentEncoding()), "zstd")
+	assert.StringContains(t, string(decodeBody(t, "zstd", conn.Response.Body())), "fail fail")
+}
+
+type fakeErr string
+
+func (e fakeErr) Error() string {
+	return string(e)
+}
+
+func enableTestCompression(minSize int) func() {
+	EnableCompression(CompressionConfig{MinSize: minSize, Pool: buffer.NewPool(8, 32, 1024)})
+	return DisableCompression
+}
+
+func compressionConn(acceptEncoding string) *fasthttp.RequestCtx {
+	conn := &fasthttp.RequestCtx{}
+	if acceptEncoding != "" {
+		conn.Request.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	return conn
+}
+
+func decodeBody(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+	var decoded []byte
+	var err error
+	switch encoding {
+	case "gzip":
+		decoded, err = fasthttp.AppendGunzipBytes(nil, body)
+	case "br":
+		decoded, err = fasthttp.AppendUnbrotliBytes(nil, body)
+	case "zstd":
+		var decoder *zstd.Decoder
+		decoder, err = zstd.NewReader(nil)
+		assert.Nil(t, err)
+		decoded, err = decoder.DecodeAll(body, nil)
+		decoder.Close()
+	}
+	assert.Nil(t, err)
+	return decoded
+}
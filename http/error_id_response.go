@@ -1,6 +1,8 @@
 package http
 
 import (
+	"errors"
+
 	"github.com/valyala/fasthttp"
 	"src.goblgobl.com/utils"
 	"src.goblgobl.com/utils/json"
@@ -20,11 +22,53 @@ var (
 					Finalize()
 )
 
+// StructuredErrors which ServerError is allowed to expose to clients, keyed
+// by code. Meant to be populated at startup, via ExposeError.
+var exposedErrors = make(map[int]exposedError)
+
+type exposedError struct {
+	message string
+	keys    []string
+}
+
+// Opts a StructuredError code in to being exposed by ServerError. When the
+// error passed to ServerError is (or wraps) a StructuredError with this code,
+// the response includes the code, the given message and the error's data,
+// limited to the given keys. Everything else about the error stays private.
+// Not thread-safe: should only be called at startup.
+func ExposeError(code int, message string, keys ...string) {
+	exposedErrors[code] = exposedError{
+		message: message,
+		keys:    keys,
+	}
+}
+
+func (e exposedError) data(data map[string]any) map[string]any {
+	var public map[string]any
+	for _, key := range e.keys {
+		value, exists := data[key]
+		if !exists {
+			continue
+		}
+		if public == nil {
+			public = make(map[string]any, len(e.keys))
+		}
+		public[key] = value
+	}
+	return public
+}
+
 type ErrorIdResponse struct {
 	Err     error
 	ErrorId string
 	Body    []byte
 	LogData log.Field
+
+	// empty unless caller capture is enabled (see log.CallerConfig)
+	Stack log.Field
+
+	// overrides the handler's application/json, when set
+	ContentType string
 }
 
 func NewErrorIdResponse(err error, errorId string, body []byte, logData log.Field) ErrorIdResponse {
@@ -37,40 +81,80 @@ func NewErrorIdResponse(err error, errorId string, body []byte, logData log.Fiel
 }
 
 func (r ErrorIdResponse) Write(conn *fasthttp.RequestCtx, logger log.Logger) log.Logger {
+	if ct := r.ContentType; ct != "" {
+		conn.SetContentType(ct)
+	}
 	conn.SetStatusCode(500)
 	conn.Response.Header.SetBytesK([]byte("Error-Id"), r.ErrorId)
-	conn.SetBody(r.Body)
-	return logger.
+	logger.
 		Err(r.Err).
 		Field(r.LogData).
-		String("eid", r.ErrorId).
-		Int("res", len(r.Body))
+		Field(r.Stack).
+		String("eid", r.ErrorId)
+	return writeBody(conn, r.Body, logger)
 }
 
 func ServerError(err error, fullError bool) Response {
 	errorId := uuid.String()
 
+	code := utils.RES_SERVER_ERROR
 	errorMessage := "internal server error"
+	var publicData map[string]any
+
+	var se *log.StructuredError
+	if errors.As(err, &se) {
+		if exposed, ok := exposedErrors[se.Code]; ok {
+			code = se.Code
+			errorMessage = exposed.message
+			publicData = exposed.data(se.Data)
+		}
+	}
+
 	if fullError {
 		errorMessage = err.Error()
 	}
 
+	if errorFormat == ErrorFormatProblem {
+		problem := newProblem(500, code, errorMessage)
+		problem.ErrorId = errorId
+		problem.Data = publicData
+		body, _ := problem.marshal()
+		res := NewErrorIdResponse(err, errorId, body, serverErrorLogData)
+		res.Stack = log.StackField(1)
+		res.ContentType = ProblemContentType
+		return res
+	}
+
 	data := struct {
-		Error   string `json:"error"`
-		ErrorId string `json:"error_id"`
-		Code    int    `json:"code"`
+		Data    map[string]any `json:"data,omitempty"`
+		Error   string         `json:"error"`
+		ErrorId string         `json:"error_id"`
+		Code    int            `json:"code"`
 	}{
+		Data:    publicData,
 		ErrorId: errorId,
 		Error:   errorMessage,
-		Code:    utils.RES_SERVER_ERROR,
+		Code:    code,
 	}
 	body, _ := json.Marshal(data)
-	return NewErrorIdResponse(err, errorId, body, serverErrorLogData)
+
+	res := NewErrorIdResponse(err, errorId, body, serverErrorLogData)
+	res.Stack = log.StackField(1)
+	return res
 }
 
 func SerializationError(err error) Response {
 	errorId := uuid.String()
 
+	if errorFormat == ErrorFormatProblem {
+		problem := newProblem(500, utils.RES_SERIALIZATION_ERROR, "internal server error")
+		problem.ErrorId = errorId
+		body, _ := problem.marshal()
+		res := NewErrorIdResponse(err, errorId, body, serializationErrorLogData)
+		res.ContentType = ProblemContentType
+		return res
+	}
+
 	data := struct {
 		Error   string `json:"error"`
 		ErrorId string `json:"error_id"`
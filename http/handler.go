@@ -15,58 +15,102 @@ type Env interface {
 	ServerError(err error, conn *fasthttp.RequestCtx) Response
 }
 
-func Handler[T Env](routeName string, loadEnv func(ctx *fasthttp.RequestCtx) (T, Response, error), next func(ctx *fasthttp.RequestCtx, env T) (Response, error)) func(ctx *fasthttp.RequestCtx) {
+func Handler[T Env](routeName string, loadEnv func(ctx *fasthttp.RequestCtx) (T, Response, error), next HandlerFunc[T]) func(ctx *fasthttp.RequestCtx) {
 	return func(conn *fasthttp.RequestCtx) {
 		start := time.Now()
+		trace := startTrace(conn)
 
 		var logger log.Logger
-		env, res, err := loadEnv(conn)
+		var panicked *PanicError
+		env, res, err := recoverLoadEnv(conn, loadEnv)
 
 		header := &conn.Response.Header
 		header.SetContentTypeBytes([]byte("application/json"))
 
 		if err != nil {
 			res = ServerError(err, false)
+			if panicked = asPanic(err); panicked != nil {
+				panicked.log(trace.Log(log.Error("http_panic")), routeName, res)
+			}
 		}
 		if res == nil {
 			// we can only be here if loadEnv didn't return a response or an error
 			// (which means it should have returned an env)
 			defer env.Release()
-			logger = env.Request(routeName)
+			logger = envRequestLogger(env, routeName, trace)
 			header.SetBytesK([]byte("RequestId"), env.RequestId())
-			res, err = next(conn, env)
+			res, err = recoverNext(conn, env, next)
 			if err != nil {
 				res = env.ServerError(err, conn)
+				if panicked = asPanic(err); panicked != nil {
+					// not env.Error, which would clobber the env's in-progress request entry
+					panicked.log(trace.Log(log.Error("http_panic")).String("rid", env.RequestId()), routeName, res)
+				}
 			}
 		} else {
-			logger = log.Request(routeName)
+			// the env (and its logger, which should have the trace) was never loaded
+			logger = trace.Log(log.Request(routeName))
+		}
+
+		finish(conn, routeName, res, logger, start)
+
+		if panicked != nil && RethrowPanics {
+			panic(panicked.Value)
 		}
+	}
+}
 
-		res.Write(conn, logger).
-			Int64("ms", time.Now().Sub(start).Milliseconds()).
-			Log()
+// The env's request logger, with the trace (either stamped by the env, if it's
+// a TracedEnv, or added to the request entry)
+func envRequestLogger[T Env](env T, routeName string, trace Trace) log.Logger {
+	if trace.IsZero() {
+		return env.Request(routeName)
+	}
+	if traced, ok := any(env).(TracedEnv); ok {
+		traced.SetTrace(trace)
+		return env.Request(routeName)
 	}
+	return trace.Log(env.Request(routeName))
 }
 
 func NoEnvHandler(routeName string, next func(ctx *fasthttp.RequestCtx) (Response, error)) func(ctx *fasthttp.RequestCtx) {
 	return func(conn *fasthttp.RequestCtx) {
 		start := time.Now()
+		trace := startTrace(conn)
 		var logger log.Logger
 
 		header := &conn.Response.Header
 		header.SetContentTypeBytes([]byte("application/json"))
 
-		res, err := next(conn)
+		var panicked *PanicError
+		res, err := recoverNoEnv(conn, next)
 
 		if err == nil {
 			logger = log.Request(routeName)
 		} else {
 			res = ServerError(err, false)
+			if panicked = asPanic(err); panicked != nil {
+				panicked.log(trace.Log(log.Error("http_panic")), routeName, res)
+			}
 			logger = log.Error("handler").String("route", routeName)
 		}
+		trace.Log(logger)
+
+		finish(conn, routeName, res, logger, start)
+
+		if panicked != nil && RethrowPanics {
+			panic(panicked.Value)
+		}
+	}
+}
+
+// Writes the response, logs the request and records its metrics
+func finish(conn *fasthttp.RequestCtx, routeName string, res Response, logger log.Logger, start time.Time) {
+	logger = res.Write(conn, logger)
+	elapsed := time.Since(start)
+	logger.Float("ms", float64(elapsed.Microseconds())/1000).Log()
 
-		res.Write(conn, logger).
-			Int64("ms", time.Now().Sub(start).Milliseconds()).
-			Log()
+	if metrics := Metrics; metrics != nil {
+		metrics.Record(routeName, conn.Response.StatusCode(), elapsed)
 	}
 }
@@ -0,0 +1,262 @@
+package http
+
+/*
+Liveness and readiness endpoints. Components register named checks:
+
+	health := http.NewHealth(time.Second, 5*time.Second)
+	health.Register("pg", 0, db.HealthCheck)
+	health.Register("sqlite", 0, sqliteChecker.Check) // see sqlite.NewHealthChecker
+	health.Register("buffers", 0, http.DepletionCheck(pool, 100))
+	health.Routes(router)
+
+GET /health (Live) always responds with {"status": "ok"}: if we can respond,
+we're alive. GET /ready (Ready) runs every check concurrently and responds
+with a 200 when they all pass, or a 503 when any fail:
+
+	{"status": "fail", "checks": {
+		"pg": {"status": "ok", "ms": 0.8},
+		"buffers": {"status": "fail", "ms": 0.01, "error": "..."}}}
+
+Each check has a timeout and its result is cached (passing or failing) for
+the Health's cacheFor, so that frequent probes don't hammer our dependencies.
+Concurrent runs of the same check are collapsed into one. Since the errors
+are included in the response, /ready shouldn't be publicly exposed.
+*/
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/sync/singleflight"
+	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/log"
+)
+
+var (
+	liveBody = []byte(`{"status":"ok"}`)
+
+	notReadyLogData = log.NewField().
+			Int("_code", utils.RES_NOT_READY).
+			Int("status", 503).
+			Finalize()
+)
+
+// Returns nil when healthy. Should respect ctx's deadline, though a check
+// which doesn't will still be considered failed once its timeout is reached.
+type HealthCheck func(ctx context.Context) error
+
+type Health struct {
+	// registered checks, in registration order
+	checks []*healthCheck
+
+	sf singleflight.Group
+
+	// default timeout of each check
+	timeout time.Duration
+
+	// how long results are cached for
+	cacheFor time.Duration
+
+	// swappable for tests
+	now func() time.Time
+}
+
+type healthCheck struct {
+	name    string
+	check   HealthCheck
+	timeout time.Duration
+	result  atomic.Pointer[checkResult]
+}
+
+type checkResult struct {
+	CheckStatus
+	expires time.Time
+}
+
+type CheckStatus struct {
+	Status string  `json:"status"`
+	Ms     float64 `json:"ms"`
+	Error  string  `json:"error,omitempty"`
+}
+
+type ReadyReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckStatus `json:"checks"`
+}
+
+// timeout is the default timeout of each check. Results are cached for
+// cacheFor (0 disables caching).
+func NewHealth(timeout time.Duration, cacheFor time.Duration) *Health {
+	return &Health{
+		timeout:  timeout,
+		cacheFor: cacheFor,
+		now:      time.Now,
+	}
+}
+
+// Registers a check. A timeout of 0 uses the Health's default. Checks must
+// be registered before Ready is called.
+func (h *Health) Register(name string, timeout time.Duration, check HealthCheck) {
+	if timeout == 0 {
+		timeout = h.timeout
+	}
+	h.checks = append(h.checks, &healthCheck{
+		name:    name,
+		check:   check,
+		timeout: timeout,
+	})
+}
+
+// Registers GET /health and GET /ready
+func (h *Health) Routes(r *Router) {
+	r.NoEnv("GET", "/health", h.Live)
+	r.NoEnv("GET", "/ready", h.Ready)
+}
+
+// The liveness endpoint
+func (h *Health) Live(conn *fasthttp.RequestCtx) (Response, error) {
+	return OKBytes(liveBody), nil
+}
+
+// The readiness endpoint
+func (h *Health) Ready(conn *fasthttp.RequestCtx) (Response, error) {
+	report := h.Check(context.Background())
+	if report.Status == "ok" {
+		return NewJSONResponse(report, 200, OKLogData), nil
+	}
+	return NewJSONResponse(report, 503, notReadyLogData), nil
+}
+
+// Runs (or uses the cached result of) every check
+func (h *Health) Check(ctx context.Context) ReadyReport {
+	checks := h.checks
+	statuses := make([]CheckStatus, len(checks))
+	errs := make([]error, len(checks))
+
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+	for i, c := range checks {
+		go func(i int, c *healthCheck) {
+			defer wg.Done()
+			statuses[i], errs[i] = h.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := ReadyReport{
+		Status: "ok",
+		Checks: make(map[string]CheckStatus, len(checks)),
+	}
+	for i, c := range checks {
+		status := statuses[i]
+		if status.Status != "ok" {
+			report.Status = "fail"
+		}
+		report.Checks[c.name] = status
+		if err := errs[i]; err != nil {
+			log.Warn("health_check").String("check", c.name).Err(err).Log()
+		}
+	}
+	return report
+}
+
+// Returns the check's error only when the check was actually run (and not
+// collapsed into another run or cached), so that failures are logged once
+func (h *Health) run(ctx context.Context, c *healthCheck) (CheckStatus, error) {
+	if cached := c.result.Load(); cached != nil && h.now().Before(cached.expires) {
+		return cached.CheckStatus, nil
+	}
+
+	// only set if this call is the one which ran the check
+	var err error
+	status, _, _ := h.sf.Do(c.name, func() (any, error) {
+		start := h.now()
+		err = c.call(ctx)
+		elapsed := h.now().Sub(start)
+
+		status := CheckStatus{
+			Status: "ok",
+			Ms:     float64(elapsed.Microseconds()) / 1000,
+		}
+		if err != nil {
+			status.Status = "fail"
+			status.Error = err.Error()
+		}
+
+		if h.cacheFor > 0 {
+			c.result.Store(&checkResult{
+				CheckStatus: status,
+				expires:     start.Add(h.cacheFor),
+			})
+		}
+		return status, nil
+	})
+	return status.(CheckStatus), err
+}
+
+// Calls the check, giving up once the timeout is reached (even if the
+// check itself ignores ctx, in which case it's left to finish in the
+// background).
+func (c *healthCheck) call(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || ctx.Err() != context.DeadlineExceeded {
+			return err
+		}
+	case <-ctx.Done():
+		if ctx.Err() != context.DeadlineExceeded {
+			// the caller's ctx was cancelled
+			return ctx.Err()
+		}
+	}
+	return log.Errf(utils.ERR_HEALTH_TIMEOUT, "health check timed out after %s", c.timeout)
+}
+
+// Anything which counts how often it was depleted, like concurrent.Pool
+// (and thus buffer.Pool)
+type Depleter interface {
+	Depleted() uint64
+}
+
+// Fails when the pool was depleted more than maxPerSecond times per second
+// since the previous run of the check. The first run always passes.
+func DepletionCheck(pool Depleter, maxPerSecond float64) HealthCheck {
+	var lock sync.Mutex
+	var lastCount uint64
+	var lastTime time.Time
+
+	return func(_ context.Context) error {
+		now := time.Now()
+		count := pool.Depleted()
+
+		lock.Lock()
+		previousCount, previousTime := lastCount, lastTime
+		lastCount, lastTime = count, now
+		lock.Unlock()
+
+		if previousTime.IsZero() {
+			return nil
+		}
+		elapsed := now.Sub(previousTime).Seconds()
+		if elapsed <= 0 {
+			return nil
+		}
+
+		rate := float64(count-previousCount) / elapsed
+		if rate > maxPerSecond {
+			return log.Errf(utils.ERR_POOL_DEPLETION, "pool depleted %.2f times per second (max %.2f)", rate, maxPerSecond)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,146 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/typed"
+)
+
+func Test_Health_Live(t *testing.T) {
+	h := NewHealth(time.Second, 0)
+	h.Register("fail", 0, func(_ context.Context) error {
+		return errors.New("nope")
+	})
+
+	res, _ := h.Live(&fasthttp.RequestCtx{})
+	r := read(res)
+	assert.Equal(t, r.status, 200)
+	assert.Equal(t, r.json.String("status"), "ok")
+}
+
+func Test_Health_Ready_OK(t *testing.T) {
+	h := NewHealth(time.Second, 0)
+	h.Register("a", 0, func(_ context.Context) error { return nil })
+	h.Register("b", 0, func(_ context.Context) error { return nil })
+
+	res, _ := h.Ready(&fasthttp.RequestCtx{})
+	r := read(res)
+	assert.Equal(t, r.status, 200)
+	assert.Equal(t, r.json.String("status"), "ok")
+	assert.Equal(t, r.json.Object("checks").Object("a").String("status"), "ok")
+	assert.Equal(t, r.json.Object("checks").Object("b").String("status"), "ok")
+}
+
+func Test_Health_Ready_Fail(t *testing.T) {
+	h := NewHealth(time.Second, 0)
+	h.Register("a", 0, func(_ context.Context) error { return nil })
+	h.Register("b", 0, func(_ context.Context) error { return errors.New("b is down") })
+
+	var r TestResponse
+	tests.CaptureLog(func() {
+		res, _ := h.Ready(&fasthttp.RequestCtx{})
+		r = read(res)
+	})
+	assert.Equal(t, r.status, 503)
+	assert.Equal(t, r.json.String("status"), "fail")
+	assert.Equal(t, r.json.Object("checks").Object("a").String("status"), "ok")
+
+	b := r.json.Object("checks").Object("b")
+	assert.Equal(t, b.String("status"), "fail")
+	assert.Equal(t, b.String("error"), "b is down")
+}
+
+func Test_Health_Timeout(t *testing.T) {
+	h := NewHealth(time.Second, 0)
+	h.Register("ctx", 5*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	block := make(chan struct{})
+	defer close(block)
+	h.Register("ignores_ctx", 5*time.Millisecond, func(_ context.Context) error {
+		<-block
+		return nil
+	})
+
+	var report ReadyReport
+	logged := tests.CaptureLog(func() {
+		report = h.Check(context.Background())
+	})
+	assert.Equal(t, report.Status, "fail")
+	assert.Equal(t, report.Checks["ctx"].Error, "code: 3013 - health check timed out after 5ms")
+	assert.Equal(t, report.Checks["ignores_ctx"].Error, "code: 3013 - health check timed out after 5ms")
+	assert.StringContains(t, logged, "_c=health_check")
+}
+
+func Test_Health_Caching(t *testing.T) {
+	var calls atomic.Int32
+	h := NewHealth(time.Second, time.Minute)
+	clock := time.Now()
+	h.now = func() time.Time { return clock }
+
+	h.Register("a", 0, func(_ context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+
+	h.Check(context.Background())
+	h.Check(context.Background())
+	assert.Equal(t, calls.Load(), 1)
+
+	clock = clock.Add(time.Minute)
+	h.Check(context.Background())
+	assert.Equal(t, calls.Load(), 2)
+}
+
+func Test_Health_Routes(t *testing.T) {
+	h := NewHealth(time.Second, 0)
+	r := NewRouter()
+	h.Routes(r)
+
+	tests.CaptureLog(func() {
+		conn := &fasthttp.RequestCtx{}
+		conn.Request.Header.SetMethod("GET")
+		conn.Request.SetRequestURI("/health")
+		r.Handler(conn)
+		assert.Equal(t, conn.Response.StatusCode(), 200)
+
+		conn = &fasthttp.RequestCtx{}
+		conn.Request.Header.SetMethod("GET")
+		conn.Request.SetRequestURI("/ready")
+		r.Handler(conn)
+		assert.Equal(t, conn.Response.StatusCode(), 200)
+		assert.Equal(t, typed.Must(conn.Response.Body()).String("status"), "ok")
+	})
+}
+
+func Test_DepletionCheck(t *testing.T) {
+	pool := &testDepleter{}
+	check := DepletionCheck(pool, 1000)
+
+	// first run establishes the baseline
+	pool.depleted.Store(1_000_000)
+	assert.Nil(t, check(context.Background()))
+
+	assert.Nil(t, check(context.Background()))
+
+	pool.depleted.Add(1_000_000)
+	err := check(context.Background())
+	assert.StringContains(t, err.Error(), "code: 3014 - pool depleted")
+}
+
+type testDepleter struct {
+	depleted atomic.Uint64
+}
+
+func (d *testDepleter) Depleted() uint64 {
+	return d.depleted.Load()
+}
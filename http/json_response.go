@@ -13,6 +13,7 @@ time.
 
 import (
 	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/buffer"
 	"src.goblgobl.com/utils/json"
 	"src.goblgobl.com/utils/log"
 
@@ -42,6 +43,12 @@ type JSONResponse struct {
 	Body    []byte
 	LogData log.Field
 	Status  int
+
+	// optional ETag, Cache-Control and Last-Modified (see OKCached)
+	Caching *Caching
+
+	// overrides the handler's application/json, when set
+	ContentType string
 }
 
 func NewJSONResponse(data any, status int, logData log.Field) Response {
@@ -61,12 +68,85 @@ func NewJSONResponse(data any, status int, logData log.Field) Response {
 }
 
 func (r JSONResponse) Write(conn *fasthttp.RequestCtx, logger log.Logger) log.Logger {
+	var etag string
+	if c := r.Caching; c != nil {
+		var current bool
+		if etag, current = c.write(conn, r.Body); current {
+			return notModified(conn, logger, etag, len(r.Body))
+		}
+	}
+	if ct := r.ContentType; ct != "" {
+		conn.SetContentType(ct)
+	}
 	conn.SetStatusCode(r.Status)
-	conn.SetBody(r.Body)
-	return logger.Field(r.LogData).Int("res", len(r.Body))
+	logger = writeBody(conn, r.Body, logger.Field(r.LogData))
+	if etag != "" {
+		setETag(conn, etag, len(conn.Response.Header.ContentEncoding()) > 0)
+	}
+	return logger
+}
+
+// Like a JSONResponse, but the body is encoded directly into a buffer
+// checked out of a pool and streamed to the client. fasthttp closes the
+// stream once the response is sent, which releases the buffer back to the
+// pool. As such, a BufferedJSONResponse must be written exactly once.
+type BufferedJSONResponse struct {
+	Buffer  *buffer.Buffer
+	LogData log.Field
+	Status  int
+}
+
+// Falls back to a SerializationError if data can't be encoded, including
+// if it exceeds the buffer's maximum size.
+func NewBufferedJSONResponse(pool buffer.Pool, data any, status int, logData log.Field) Response {
+	if data == nil {
+		return JSONResponse{Status: status, LogData: logData}
+	}
+
+	buf := pool.Checkout()
+	if err := json.MarshalInto(data, buf); err != nil {
+		buf.Release()
+		return SerializationError(err)
+	}
+
+	// the encoder terminates the value with a newline, which Marshal
+	// (and thus JSONResponse) doesn't
+	buf.Truncate(1)
+
+	return BufferedJSONResponse{
+		Buffer:  buf,
+		Status:  status,
+		LogData: logData,
+	}
+}
+
+func (r BufferedJSONResponse) Write(conn *fasthttp.RequestCtx, logger log.Logger) log.Logger {
+	conn.SetStatusCode(r.Status)
+	l := r.Buffer.Len()
+	logger.Field(r.LogData).Int("res", l)
+
+	if buf, encoding := compressBody(conn, r.Buffer.OKBytes()); buf != nil {
+		r.Buffer.Release()
+		conn.SetBodyStream(buf, buf.Len())
+		return logEncoded(logger, encoding, buf.Len())
+	}
+
+	conn.SetBodyStream(r.Buffer, l)
+	return logger
 }
 
 func Validation(validator ValidationProvider) Response {
+	if errorFormat == ErrorFormatProblem {
+		problem := newProblem(400, utils.RES_VALIDATION, "invalid data")
+		problem.Invalid = validator.Errors()
+		res := NewJSONResponse(problem, 400, ValidationLogData)
+		if r, ok := res.(JSONResponse); ok {
+			r.ContentType = ProblemContentType
+			return r
+		}
+		return res
+	}
+
 	data := struct {
 		Error   string `json:"error"`
 		Invalid []any  `json:"invalid"`
@@ -94,3 +174,7 @@ func OKBytes(body []byte) Response {
 func Created(data any) Response {
 	return NewJSONResponse(data, 201, CreatedLogData)
 }
+
+func BufferedOK(pool buffer.Pool, data any) Response {
+	return NewBufferedJSONResponse(pool, data, 200, OKLogData)
+}
@@ -0,0 +1,219 @@
+package http
+
+/*
+Per-request metrics. When Metrics is set, Handler and NoEnvHandler record
+every request's route, status and duration with it.
+
+MemoryMetrics is an in-process recorder which keeps, per route, the number
+of requests by status class (2xx, 4xx, ...) and a latency histogram with
+fixed buckets. Recording is lock-free: routes are looked up in a
+copy-on-write map (a lock is only taken the first time a route is seen) and
+counters are atomic. Its Handler serves the metrics in the Prometheus text
+format.
+*/
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type MetricsRecorder interface {
+	Record(route string, status int, duration time.Duration)
+}
+
+var (
+	// Set at startup to record request metrics (nil disables)
+	Metrics MetricsRecorder
+
+	DefaultMetricBuckets = []time.Duration{
+		5 * time.Millisecond,
+		10 * time.Millisecond,
+		25 * time.Millisecond,
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		250 * time.Millisecond,
+		500 * time.Millisecond,
+		time.Second,
+		2500 * time.Millisecond,
+		5 * time.Second,
+		10 * time.Second,
+	}
+
+	statusClassLabels = [6]string{"other", "1xx", "2xx", "3xx", "4xx", "5xx"}
+)
+
+type MemoryMetrics struct {
+	// upper bounds, ascending
+	buckets []time.Duration
+
+	// route => *routeMetrics, replaced (never mutated) when a route is added
+	routes atomic.Pointer[map[string]*routeMetrics]
+
+	// serializes adding routes
+	lock sync.Mutex
+}
+
+type routeMetrics struct {
+	// indexed by status/100 (0 for anything outside of 100-599)
+	statuses [6]atomic.Uint64
+
+	// count per bucket (not cumulative), with a final +Inf bucket
+	buckets []atomic.Uint64
+
+	// total duration, in nanoseconds
+	sum atomic.Uint64
+}
+
+// buckets are the upper bounds of the latency histogram. nil uses
+// DefaultMetricBuckets.
+func NewMemoryMetrics(buckets []time.Duration) *MemoryMetrics {
+	if buckets == nil {
+		buckets = DefaultMetricBuckets
+	}
+
+	sorted := make([]time.Duration, len(buckets))
+	copy(sorted, buckets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	m := &MemoryMetrics{buckets: sorted}
+	m.routes.Store(&map[string]*routeMetrics{})
+	return m
+}
+
+func (m *MemoryMetrics) Record(route string, status int, duration time.Duration) {
+	rm := m.route(route)
+
+	class := status / 100
+	if class < 1 || class > 5 {
+		class = 0
+	}
+	rm.statuses[class].Add(1)
+
+	buckets := m.buckets
+	i := sort.Search(len(buckets), func(i int) bool {
+		return duration <= buckets[i]
+	})
+	rm.buckets[i].Add(1)
+
+	if duration > 0 {
+		rm.sum.Add(uint64(duration))
+	}
+}
+
+func (m *MemoryMetrics) route(route string) *routeMetrics {
+	if rm, exists := (*m.routes.Load())[route]; exists {
+		return rm
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	existing := *m.routes.Load()
+	if rm, exists := existing[route]; exists {
+		return rm
+	}
+
+	routes := make(map[string]*routeMetrics, len(existing)+1)
+	for name, rm := range existing {
+		routes[name] = rm
+	}
+
+	rm := &routeMetrics{buckets: make([]atomic.Uint64, len(m.buckets)+1)}
+	routes[route] = rm
+	m.routes.Store(&routes)
+	return rm
+}
+
+// A fasthttp handler which serves the metrics in the Prometheus text format
+func (m *MemoryMetrics) Handler() func(conn *fasthttp.RequestCtx) {
+	return func(conn *fasthttp.RequestCtx) {
+		conn.SetContentType("text/plain; version=0.0.4; charset=utf-8")
+		conn.SetBody(m.AppendPrometheus(nil))
+	}
+}
+
+// Appends the metrics, in the Prometheus text format, to dst
+func (m *MemoryMetrics) AppendPrometheus(dst []byte) []byte {
+	routes := *m.routes.Load()
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dst = append(dst, "# HELP http_requests_total Number of requests, by route and status class.\n# TYPE http_requests_total counter\n"...)
+	for _, name := range names {
+		rm := routes[name]
+		for class := range rm.statuses {
+			count := rm.statuses[class].Load()
+			if count == 0 {
+				continue
+			}
+			dst = append(dst, "http_requests_total{route=\""...)
+			dst = appendLabelValue(dst, name)
+			dst = append(dst, "\",status=\""...)
+			dst = append(dst, statusClassLabels[class]...)
+			dst = append(dst, "\"} "...)
+			dst = strconv.AppendUint(dst, count, 10)
+			dst = append(dst, '\n')
+		}
+	}
+
+	dst = append(dst, "# HELP http_request_duration_seconds Request latency, by route.\n# TYPE http_request_duration_seconds histogram\n"...)
+	for _, name := range names {
+		rm := routes[name]
+
+		var cumulative uint64
+		for i := range rm.buckets {
+			cumulative += rm.buckets[i].Load()
+			dst = append(dst, "http_request_duration_seconds_bucket{route=\""...)
+			dst = appendLabelValue(dst, name)
+			dst = append(dst, "\",le=\""...)
+			if i < len(m.buckets) {
+				dst = strconv.AppendFloat(dst, m.buckets[i].Seconds(), 'g', -1, 64)
+			} else {
+				dst = append(dst, "+Inf"...)
+			}
+			dst = append(dst, "\"} "...)
+			dst = strconv.AppendUint(dst, cumulative, 10)
+			dst = append(dst, '\n')
+		}
+
+		dst = append(dst, "http_request_duration_seconds_sum{route=\""...)
+		dst = appendLabelValue(dst, name)
+		dst = append(dst, "\"} "...)
+		dst = strconv.AppendFloat(dst, time.Duration(rm.sum.Load()).Seconds(), 'g', -1, 64)
+		dst = append(dst, '\n')
+
+		dst = append(dst, "http_request_duration_seconds_count{route=\""...)
+		dst = appendLabelValue(dst, name)
+		dst = append(dst, "\"} "...)
+		dst = strconv.AppendUint(dst, cumulative, 10)
+		dst = append(dst, '\n')
+	}
+	return dst
+}
+
+// Label values escape backslash, double-quote and newline
+func appendLabelValue(dst []byte, value string) []byte {
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\':
+			dst = append(dst, '\\', '\\')
+		case '"':
+			dst = append(dst, '\\', '"')
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests"
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_MemoryMetrics_Empty(t *testing.T) {
+	m := NewMemoryMetrics(nil)
+	assert.Equal(t, string(m.AppendPrometheus(nil)), strings.Join([]string{
+		"# HELP http_requests_total Number of requests, by route and status class.",
+		"# TYPE http_requests_total counter",
+		"# HELP http_request_duration_seconds Request latency, by route.",
+		"# TYPE http_request_duration_seconds histogram",
+		"",
+	}, "\n"))
+}
+
+func Test_MemoryMetrics_Record(t *testing.T) {
+	m := NewMemoryMetrics([]time.Duration{100 * time.Millisecond, 10 * time.Millisecond})
+	m.Record("users", 200, 5*time.Millisecond)
+	m.Record("users", 201, 10*time.Millisecond)
+	m.Record("users", 404, 50*time.Millisecond)
+	m.Record("users", 500, 2*time.Second)
+	m.Record("a\"b", 0, 0)
+
+	assert.Equal(t, string(m.AppendPrometheus(nil)), strings.Join([]string{
+		"# HELP http_requests_total Number of requests, by route and status class.",
+		"# TYPE http_requests_total counter",
+		`http_requests_total{route="a\"b",status="other"} 1`,
+		`http_requests_total{route="users",status="2xx"} 2`,
+		`http_requests_total{route="users",status="4xx"} 1`,
+		`http_requests_total{route="users",status="5xx"} 1`,
+		"# HELP http_request_duration_seconds Request latency, by route.",
+		"# TYPE http_request_duration_seconds histogram",
+		`http_request_duration_seconds_bucket{route="a\"b",le="0.01"} 1`,
+		`http_request_duration_seconds_bucket{route="a\"b",le="0.1"} 1`,
+		`http_request_duration_seconds_bucket{route="a\"b",le="+Inf"} 1`,
+		`http_request_duration_seconds_sum{route="a\"b"} 0`,
+		`http_request_duration_seconds_count{route="a\"b"} 1`,
+		`http_request_duration_seconds_bucket{route="users",le="0.01"} 2`,
+		`http_request_duration_seconds_bucket{route="users",le="0.1"} 3`,
+		`http_request_duration_seconds_bucket{route="users",le="+Inf"} 4`,
+		`http_request_duration_seconds_sum{route="users"} 2.065`,
+		`http_request_duration_seconds_count{route="users"} 4`,
+		"",
+	}, "\n"))
+}
+
+func Test_MemoryMetrics_Concurrent(t *testing.T) {
+	m := NewMemoryMetrics(nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			routes := []string{"a", "b", "c"}
+			for j := 0; j < 1000; j++ {
+				m.Record(routes[(i+j)%3], 200, time.Millisecond)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	total := uint64(0)
+	for _, rm := range *m.routes.Load() {
+		total += rm.statuses[2].Load()
+	}
+	assert.Equal(t, total, 10000)
+}
+
+func Test_MemoryMetrics_Handler(t *testing.T) {
+	m := NewMemoryMetrics(nil)
+	m.Record("users", 200, time.Millisecond)
+
+	conn := &fasthttp.RequestCtx{}
+	m.Handler()(conn)
+	assert.Equal(t, string(conn.Response.Header.ContentType()), "text/plain; version=0.0.4; charset=utf-8")
+	assert.StringContains(t, string(conn.Response.Body()), `http_requests_total{route="users",status="2xx"} 1`)
+}
+
+func Test_Handler_RecordsMetrics(t *testing.T) {
+	m := NewMemoryMetrics(nil)
+	Metrics = m
+	defer func() { Metrics = nil }()
+
+	testLoader := func(conn *fasthttp.RequestCtx) (*TestEnv, Response, error) {
+		return testEnv(1), nil, nil
+	}
+
+	tests.CaptureLog(func() {
+		Handler("env_route", testLoader, func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+			return StaticError(404, 1, ""), nil
+		})(&fasthttp.RequestCtx{})
+
+		NoEnvHandler("no_env_route", func(conn *fasthttp.RequestCtx) (Response, error) {
+			return OK(nil), nil
+		})(&fasthttp.RequestCtx{})
+	})
+
+	routes := *m.routes.Load()
+	assert.Equal(t, routes["env_route"].statuses[4].Load(), 1)
+	assert.Equal(t, routes["no_env_route"].statuses[2].Load(), 1)
+}
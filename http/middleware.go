@@ -0,0 +1,277 @@
+package http
+
+/*
+Middleware wraps the part of a handler which runs with a loaded env (the
+next passed to Handler). A Chain composes middlewares, the first being the
+outermost:
+
+	chain := NewChain(CORS[*Env](corsConfig), BodyLimit[*Env](65536))
+	Handler("users_create", loadEnv, chain.Then(users.Create))
+
+Since they run inside Handler, middlewares don't need to deal with loading
+the env, panics, the RequestId header or logging. A middleware can
+short-circuit the request by returning a Response without calling next.
+*/
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/log"
+)
+
+const contextUserValue = "_ctx"
+
+var (
+	BodyTooLarge = StaticError(413, utils.RES_BODY_TOO_LARGE, "request body too large")
+	Unauthorized = StaticError(401, utils.RES_UNAUTHORIZED, "unauthorized")
+	TimedOut     = StaticError(503, utils.RES_TIMEOUT, "request timed out")
+
+	preflightResponse = StaticResponse{
+		status:  204,
+		logData: log.NewField().Int("status", 204).Finalize(),
+	}
+
+	originHeader           = []byte("Origin")
+	varyHeader             = []byte("Vary")
+	authorizationHeader    = []byte("Authorization")
+	wwwAuthenticateHeader  = []byte("WWW-Authenticate")
+	allowOriginHeader      = []byte("Access-Control-Allow-Origin")
+	allowCredentialsHeader = []byte("Access-Control-Allow-Credentials")
+	allowMethodsHeader     = []byte("Access-Control-Allow-Methods")
+	allowHeadersHeader     = []byte("Access-Control-Allow-Headers")
+	exposeHeadersHeader    = []byte("Access-Control-Expose-Headers")
+	maxAgeHeader           = []byte("Access-Control-Max-Age")
+	requestMethodHeader    = []byte("Access-Control-Request-Method")
+	requestHeadersHeader   = []byte("Access-Control-Request-Headers")
+	space                  = []byte(" ")
+
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+)
+
+type HandlerFunc[T Env] func(conn *fasthttp.RequestCtx, env T) (Response, error)
+
+type Middleware[T Env] func(next HandlerFunc[T]) HandlerFunc[T]
+
+type Chain[T Env] struct {
+	middlewares []Middleware[T]
+}
+
+func NewChain[T Env](middlewares ...Middleware[T]) Chain[T] {
+	return Chain[T]{middlewares: middlewares}
+}
+
+// Returns a new chain with the given middlewares added after (that is,
+// inside of) the existing ones. c is unchanged.
+func (c Chain[T]) Append(middlewares ...Middleware[T]) Chain[T] {
+	combined := make([]Middleware[T], 0, len(c.middlewares)+len(middlewares))
+	combined = append(combined, c.middlewares...)
+	return Chain[T]{middlewares: append(combined, middlewares...)}
+}
+
+// Wraps handler with the chain's middlewares
+func (c Chain[T]) Then(handler HandlerFunc[T]) HandlerFunc[T] {
+	middlewares := c.middlewares
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+type CORSConfig struct {
+	// Origins (e.g. "https://app.example.com") allowed to make requests.
+	// "*" allows any origin.
+	AllowOrigins []string
+
+	// Methods allowed in preflight requests (defaults to GET, POST, PUT,
+	// PATCH and DELETE)
+	AllowMethods []string
+
+	// Headers allowed in preflight requests. When empty, whatever headers
+	// the preflight requests are allowed.
+	AllowHeaders []string
+
+	// Response headers which the browser exposes to the client
+	ExposeHeaders []string
+
+	// Can't be combined with the "*" origin (which would let any site make
+	// credentialed requests)
+	AllowCredentials bool
+
+	// How long preflight responses can be cached (0 omits the header)
+	MaxAge time.Duration
+}
+
+// Cross-Origin Resource Sharing. Requests from an allowed origin get the
+// Access-Control-* response headers. Preflight requests (an OPTIONS with an
+// Access-Control-Request-Method header) are answered with a 204 without
+// calling next. Requests from other origins are passed to next without any
+// CORS headers (which the browser enforces).
+//
+// Since this runs within Handler, the route's env loader must be able to
+// handle preflight requests (e.g. not require authentication).
+//
+// Panics if the "*" origin is combined with AllowCredentials (middleware is
+// meant to be created at startup).
+func CORS[T Env](config CORSConfig) Middleware[T] {
+	anyOrigin := false
+	origins := make(map[string]struct{}, len(config.AllowOrigins))
+	for _, origin := range config.AllowOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		origins[origin] = struct{}{}
+	}
+	if anyOrigin && config.AllowCredentials {
+		panic("CORS can't allow credentials from any origin (\"*\")")
+	}
+
+	methods := config.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(config.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+
+	var maxAge string
+	if config.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(config.MaxAge.Seconds()))
+	}
+
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(conn *fasthttp.RequestCtx, env T) (Response, error) {
+			origin := conn.Request.Header.PeekBytes(originHeader)
+			if len(origin) == 0 {
+				return next(conn, env)
+			}
+
+			header := &conn.Response.Header
+			if !anyOrigin {
+				header.AddBytesK(varyHeader, "Origin")
+			}
+
+			if _, allowed := origins[utils.B2S(origin)]; !allowed && !anyOrigin {
+				return next(conn, env)
+			}
+
+			if anyOrigin {
+				header.SetBytesK(allowOriginHeader, "*")
+			} else {
+				header.SetBytesKV(allowOriginHeader, origin)
+			}
+			if config.AllowCredentials {
+				header.SetBytesK(allowCredentialsHeader, "true")
+			}
+
+			if !conn.IsOptions() || len(conn.Request.Header.PeekBytes(requestMethodHeader)) == 0 {
+				if exposeHeaders != "" {
+					header.SetBytesK(exposeHeadersHeader, exposeHeaders)
+				}
+				return next(conn, env)
+			}
+
+			header.SetBytesK(allowMethodsHeader, allowMethods)
+			if allowHeaders != "" {
+				header.SetBytesK(allowHeadersHeader, allowHeaders)
+			} else if requested := conn.Request.Header.PeekBytes(requestHeadersHeader); len(requested) > 0 {
+				header.SetBytesKV(allowHeadersHeader, requested)
+			}
+			if maxAge != "" {
+				header.SetBytesK(maxAgeHeader, maxAge)
+			}
+			return preflightResponse, nil
+		}
+	}
+}
+
+// Rejects requests with a body larger than max bytes with BodyTooLarge. This
+// is a per-route limit, on top of the server's MaxRequestBodySize.
+func BodyLimit[T Env](max int) Middleware[T] {
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(conn *fasthttp.RequestCtx, env T) (Response, error) {
+			if conn.Request.Header.ContentLength() > max || len(conn.Request.Body()) > max {
+				return BodyTooLarge, nil
+			}
+			return next(conn, env)
+		}
+	}
+}
+
+// Extracts the credentials of the Authorization header (the part after
+// scheme, e.g. the token of "Bearer $token") and passes them to authenticate.
+// The scheme is matched case-insensitively. A missing header, a different
+// scheme or empty credentials result in Unauthorized (with a
+// WWW-Authenticate header). authenticate rejects the request by returning a
+// Response (or an error); returning nil, nil continues to next.
+func Auth[T Env](scheme string, authenticate func(conn *fasthttp.RequestCtx, env T, credentials string) (Response, error)) Middleware[T] {
+	prefix := []byte(scheme)
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(conn *fasthttp.RequestCtx, env T) (Response, error) {
+			credentials, ok := authorizationCredentials(conn.Request.Header.PeekBytes(authorizationHeader), prefix)
+			if !ok {
+				conn.Response.Header.SetBytesK(wwwAuthenticateHeader, scheme)
+				return Unauthorized, nil
+			}
+
+			res, err := authenticate(conn, env, string(credentials))
+			if res != nil || err != nil {
+				return res, err
+			}
+			return next(conn, env)
+		}
+	}
+}
+
+// "Bearer abc123" => "abc123"
+func authorizationCredentials(value []byte, scheme []byte) ([]byte, bool) {
+	found, credentials, ok := bytes.Cut(value, space)
+	if !ok || !bytes.EqualFold(found, scheme) {
+		return nil, false
+	}
+	credentials = bytes.TrimSpace(credentials)
+	return credentials, len(credentials) > 0
+}
+
+// Gives next a deadline of d, available via Context(conn). The timeout is
+// cooperative: next (and what it calls, such as the pg *Context functions)
+// is expected to honor the context. If next fails once the deadline has
+// passed, TimedOut is returned instead of the error.
+func Timeout[T Env](d time.Duration) Middleware[T] {
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(conn *fasthttp.RequestCtx, env T) (Response, error) {
+			parent := conn.UserValue(contextUserValue)
+			ctx, cancel := context.WithTimeout(Context(conn), d)
+			defer func() {
+				cancel()
+				if parent == nil {
+					conn.RemoveUserValue(contextUserValue)
+				} else {
+					conn.SetUserValue(contextUserValue, parent)
+				}
+			}()
+
+			conn.SetUserValue(contextUserValue, ctx)
+			res, err := next(conn, env)
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return TimedOut, nil
+			}
+			return res, err
+		}
+	}
+}
+
+// The request's context: the one given by the Timeout middleware or, by
+// default, the RequestCtx itself (which is done when the server shuts down).
+func Context(conn *fasthttp.RequestCtx) context.Context {
+	if ctx, ok := conn.UserValue(contextUserValue).(context.Context); ok {
+		return ctx
+	}
+	return conn
+}
@@ -0,0 +1,231 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
+)
+
+func Test_Chain_Order(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware[*TestEnv] {
+		return func(next HandlerFunc[*TestEnv]) HandlerFunc[*TestEnv] {
+			return func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+				calls = append(calls, name)
+				return next(conn, env)
+			}
+		}
+	}
+
+	base := NewChain(mw("a"), mw("b"))
+	extended := base.Append(mw("c"))
+
+	handler := func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+		calls = append(calls, "handler")
+		return OK(nil), nil
+	}
+
+	extended.Then(handler)(&fasthttp.RequestCtx{}, testEnv(1))
+	assert.List(t, calls, []string{"a", "b", "c", "handler"})
+
+	calls = nil
+	base.Then(handler)(&fasthttp.RequestCtx{}, testEnv(1))
+	assert.List(t, calls, []string{"a", "b", "handler"})
+
+	calls = nil
+	NewChain[*TestEnv]().Then(handler)(&fasthttp.RequestCtx{}, testEnv(1))
+	assert.List(t, calls, []string{"handler"})
+}
+
+func Test_Chain_WithHandler(t *testing.T) {
+	testLoader := func(conn *fasthttp.RequestCtx) (*TestEnv, Response, error) {
+		return testEnv(1), nil, nil
+	}
+	chain := NewChain(BodyLimit[*TestEnv](2))
+
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.SetBodyString("over")
+	Handler("", testLoader, chain.Then(func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+		assert.Fail(t, "next should not be called")
+		return nil, nil
+	}))(conn)
+	assert.Equal(t, conn.Response.StatusCode(), 413)
+	assertCode(t, conn, 2005)
+}
+
+func Test_CORS_NoOrigin(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	res := runMiddleware(t, CORS[*TestEnv](CORSConfig{AllowOrigins: []string{"*"}}), conn)
+	assert.Equal(t, res, "next")
+	assert.Equal(t, string(conn.Response.Header.PeekBytes(allowOriginHeader)), "")
+}
+
+func Test_CORS_DisallowedOrigin(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("Origin", "https://evil.example.com")
+	res := runMiddleware(t, CORS[*TestEnv](CORSConfig{AllowOrigins: []string{"https://app.example.com"}}), conn)
+	assert.Equal(t, res, "next")
+	assert.Equal(t, string(conn.Response.Header.PeekBytes(allowOriginHeader)), "")
+	assert.Equal(t, string(conn.Response.Header.Peek("Vary")), "Origin")
+}
+
+func Test_CORS_AllowedOrigin(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("Origin", "https://app.example.com")
+	res := runMiddleware(t, CORS[*TestEnv](CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com"},
+		ExposeHeaders:    []string{"Error-Id", "RequestId"},
+		AllowCredentials: true,
+	}), conn)
+
+	header := &conn.Response.Header
+	assert.Equal(t, res, "next")
+	assert.Equal(t, string(header.PeekBytes(allowOriginHeader)), "https://app.example.com")
+	assert.Equal(t, string(header.PeekBytes(allowCredentialsHeader)), "true")
+	assert.Equal(t, string(header.PeekBytes(exposeHeadersHeader)), "Error-Id, RequestId")
+	assert.Equal(t, string(header.PeekBytes(allowMethodsHeader)), "")
+}
+
+func Test_CORS_AnyOrigin(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("Origin", "https://app.example.com")
+	runMiddleware(t, CORS[*TestEnv](CORSConfig{AllowOrigins: []string{"*"}}), conn)
+	assert.Equal(t, string(conn.Response.Header.PeekBytes(allowOriginHeader)), "*")
+	assert.Equal(t, string(conn.Response.Header.Peek("Vary")), "")
+
+	// any origin with credentials would let any site make credentialed requests
+	assertPanics(t, func() {
+		CORS[*TestEnv](CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+	})
+}
+
+func Test_CORS_Preflight(t *testing.T) {
+	mw := CORS[*TestEnv](CORSConfig{
+		AllowOrigins: []string{"https://app.example.com"},
+		MaxAge:       10 * time.Minute,
+	})
+
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.SetMethod("OPTIONS")
+	conn.Request.Header.Set("Origin", "https://app.example.com")
+	conn.Request.Header.Set("Access-Control-Request-Method", "PUT")
+	conn.Request.Header.Set("Access-Control-Request-Headers", "Content-Type, Authorization")
+	res := runMiddleware(t, mw, conn)
+
+	header := &conn.Response.Header
+	assert.Equal(t, res, "")
+	assert.Equal(t, conn.Response.StatusCode(), 204)
+	assert.Equal(t, string(header.PeekBytes(allowOriginHeader)), "https://app.example.com")
+	assert.Equal(t, string(header.PeekBytes(allowMethodsHeader)), "GET, POST, PUT, PATCH, DELETE")
+	assert.Equal(t, string(header.PeekBytes(allowHeadersHeader)), "Content-Type, Authorization")
+	assert.Equal(t, string(header.PeekBytes(maxAgeHeader)), "600")
+
+	// OPTIONS without Access-Control-Request-Method isn't a preflight
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.Header.SetMethod("OPTIONS")
+	conn.Request.Header.Set("Origin", "https://app.example.com")
+	assert.Equal(t, runMiddleware(t, mw, conn), "next")
+}
+
+func Test_BodyLimit(t *testing.T) {
+	mw := BodyLimit[*TestEnv](4)
+
+	conn := &fasthttp.RequestCtx{}
+	assert.Equal(t, runMiddleware(t, mw, conn), "next")
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.SetBodyString("1234")
+	assert.Equal(t, runMiddleware(t, mw, conn), "next")
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.SetBodyString("12345")
+	assert.Equal(t, runMiddleware(t, mw, conn), "")
+	assert.Equal(t, conn.Response.StatusCode(), 413)
+}
+
+func Test_Auth(t *testing.T) {
+	var seen string
+	mw := Auth[*TestEnv]("Bearer", func(conn *fasthttp.RequestCtx, env *TestEnv, credentials string) (Response, error) {
+		seen = credentials
+		if credentials == "bad" {
+			return StaticError(403, 9, "forbidden"), nil
+		}
+		if credentials == "fail" {
+			return nil, errors.New("auth store down")
+		}
+		return nil, nil
+	})
+
+	for _, value := range []string{"", "Bearer", "Bearer  ", "Basic abc", "abc"} {
+		conn := &fasthttp.RequestCtx{}
+		if value != "" {
+			conn.Request.Header.Set("Authorization", value)
+		}
+		assert.Equal(t, runMiddleware(t, mw, conn), "")
+		assert.Equal(t, conn.Response.StatusCode(), 401)
+		assert.Equal(t, string(conn.Response.Header.Peek("WWW-Authenticate")), "Bearer")
+	}
+
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("Authorization", "bearer token1")
+	assert.Equal(t, runMiddleware(t, mw, conn), "next")
+	assert.Equal(t, seen, "token1")
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("Authorization", "Bearer bad")
+	assert.Equal(t, runMiddleware(t, mw, conn), "")
+	assert.Equal(t, conn.Response.StatusCode(), 403)
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("Authorization", "Bearer fail")
+	_, err := mw(nextMiddleware)(conn, testEnv(1))
+	assert.Equal(t, err.Error(), "auth store down")
+}
+
+func Test_Timeout(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Init(&fasthttp.Request{}, nil, nil)
+	assert.True(t, Context(conn) == context.Context(conn))
+
+	res, err := Timeout[*TestEnv](time.Millisecond)(func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+		ctx := Context(conn)
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})(conn, testEnv(1))
+	assert.Nil(t, err)
+	assert.Equal(t, res.(StaticResponse).status, 503)
+
+	// restored once next returns
+	assert.True(t, Context(conn) == context.Context(conn))
+
+	// errors which happen before the deadline are returned as-is
+	_, err = Timeout[*TestEnv](time.Minute)(func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+		return nil, errors.New("not a timeout")
+	})(conn, testEnv(1))
+	assert.Equal(t, err.Error(), "not a timeout")
+}
+
+func nextMiddleware(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+	conn.SetUserValue("next", true)
+	return OK(nil), nil
+}
+
+// "next" if the middleware called next, else "" (the response, if any, is
+// written to conn)
+func runMiddleware(t *testing.T, mw Middleware[*TestEnv], conn *fasthttp.RequestCtx) string {
+	t.Helper()
+	res, err := mw(nextMiddleware)(conn, testEnv(1))
+	assert.Nil(t, err)
+	if conn.UserValue("next") != nil {
+		return "next"
+	}
+	res.Write(conn, log.Noop{})
+	return ""
+}
@@ -0,0 +1,91 @@
+package http
+
+/*
+Handler and NoEnvHandler recover from panics in loadEnv and next. The panic
+is converted into a PanicError, which goes through the normal error path
+(and thus results in an ErrorIdResponse with a new error id). The panic's
+value and stack are logged in a separate ERROR entry (http_panic), along with
+the route and error id. The stack is captured regardless of log.CallerConfig.
+
+By default, the panic is then swallowed. With RethrowPanics, it's re-raised
+once the response has been written and the request logged (and, for
+Handler, after the env has been released).
+*/
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils/log"
+)
+
+// Whether recovered panics are re-raised after being logged
+var RethrowPanics = false
+
+// A panic recovered from a handler's loadEnv or next
+type PanicError struct {
+	Value any
+
+	// the stack at the point of the panic
+	Stack log.Field
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func recoverLoadEnv[T Env](conn *fasthttp.RequestCtx, loadEnv func(ctx *fasthttp.RequestCtx) (T, Response, error)) (env T, res Response, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = newPanicError(value)
+		}
+	}()
+	return loadEnv(conn)
+}
+
+func recoverNext[T Env](conn *fasthttp.RequestCtx, env T, next HandlerFunc[T]) (res Response, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = newPanicError(value)
+		}
+	}()
+	return next(conn, env)
+}
+
+func recoverNoEnv(conn *fasthttp.RequestCtx, next func(ctx *fasthttp.RequestCtx) (Response, error)) (res Response, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = newPanicError(value)
+		}
+	}()
+	return next(conn)
+}
+
+// Called from the deferred function which recovered the panic. Skipping that
+// function (runtime frames are always left out), the stack starts at the
+// function which panicked.
+func newPanicError(value any) *PanicError {
+	return &PanicError{
+		Value: value,
+		Stack: log.ForceStackField(2),
+	}
+}
+
+// The PanicError err is (or wraps), or nil
+func asPanic(err error) *PanicError {
+	var pe *PanicError
+	if errors.As(err, &pe) {
+		return pe
+	}
+	return nil
+}
+
+// Logs the panic's value and stack, along with the route and error id
+func (e *PanicError) log(logger log.Logger, routeName string, res Response) {
+	logger.String("route", routeName).String("panic", fmt.Sprint(e.Value)).Field(e.Stack)
+	if r, ok := res.(ErrorIdResponse); ok {
+		logger.String("eid", r.ErrorId)
+	}
+	logger.Log()
+}
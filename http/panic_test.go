@@ -0,0 +1,133 @@
+package http
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
+)
+
+func Test_Handler_RecoversNextPanic(t *testing.T) {
+	env := testEnv(300)
+	testLoader := func(conn *fasthttp.RequestCtx) (*TestEnv, Response, error) {
+		return env, nil, nil
+	}
+
+	conn := &fasthttp.RequestCtx{}
+	logged := tests.CaptureLog(func() {
+		Handler("panic_route", testLoader, func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+			panic("over 9000")
+		})(conn)
+	})
+	assert.True(t, env.released)
+
+	res := &conn.Response
+	assert.Equal(t, res.StatusCode(), 500)
+	errorId := string(res.Header.Peek("Error-Id"))
+	assert.Equal(t, len(errorId), 36)
+	assertCode(t, conn, 2001)
+
+	panicLog, reqLog := parsePanicLog(t, logged)
+	assert.Equal(t, panicLog["_l"], "error")
+	assert.Equal(t, panicLog["_c"], "http_panic")
+	assert.Equal(t, panicLog["route"], "panic_route")
+	assert.Equal(t, panicLog["panic"], `"over 9000"`)
+	assert.Equal(t, panicLog["eid"], errorId)
+	assert.True(t, strings.HasPrefix(panicLog["_stack"], "http.Test_Handler_RecoversNextPanic."))
+
+	assert.Equal(t, reqLog["_l"], "req")
+	assert.Equal(t, reqLog["_c"], "panic_route")
+	assert.Equal(t, reqLog["_err"], `"wrapped(panic: over 9000)"`)
+	assert.Equal(t, reqLog["eid"], errorId)
+}
+
+func Test_Handler_RecoversEnvLoaderPanic(t *testing.T) {
+	testLoader := func(conn *fasthttp.RequestCtx) (*TestEnv, Response, error) {
+		var m map[string]int
+		m["boom"] = 1
+		return nil, nil, nil
+	}
+
+	conn := &fasthttp.RequestCtx{}
+	logged := tests.CaptureLog(func() {
+		Handler("panic_route", testLoader, func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+			assert.Fail(t, "next should not be called")
+			return nil, nil
+		})(conn)
+	})
+
+	res := &conn.Response
+	assert.Equal(t, res.StatusCode(), 500)
+	errorId := string(res.Header.Peek("Error-Id"))
+
+	panicLog, reqLog := parsePanicLog(t, logged)
+	assert.Equal(t, panicLog["_c"], "http_panic")
+	assert.Equal(t, panicLog["route"], "panic_route")
+	assert.Equal(t, panicLog["panic"], `"assignment to entry in nil map"`)
+	assert.Equal(t, panicLog["eid"], errorId)
+	assert.True(t, strings.HasPrefix(panicLog["_stack"], "http.Test_Handler_RecoversEnvLoaderPanic."))
+
+	assert.Equal(t, reqLog["_l"], "req")
+	assert.Equal(t, reqLog["_code"], "2001")
+	assert.Equal(t, reqLog["eid"], errorId)
+}
+
+func Test_NoEnvHandler_RecoversPanic(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	logged := tests.CaptureLog(func() {
+		NoEnvHandler("panic_route", func(conn *fasthttp.RequestCtx) (Response, error) {
+			panic(9001)
+		})(conn)
+	})
+
+	res := &conn.Response
+	assert.Equal(t, res.StatusCode(), 500)
+	errorId := string(res.Header.Peek("Error-Id"))
+
+	panicLog, errLog := parsePanicLog(t, logged)
+	assert.Equal(t, panicLog["_c"], "http_panic")
+	assert.Equal(t, panicLog["panic"], "9001")
+	assert.Equal(t, panicLog["eid"], errorId)
+
+	assert.Equal(t, errLog["_c"], "handler")
+	assert.Equal(t, errLog["_err"], `"panic: 9001"`)
+	assert.Equal(t, errLog["eid"], errorId)
+}
+
+func Test_Handler_RethrowPanics(t *testing.T) {
+	RethrowPanics = true
+	defer func() { RethrowPanics = false }()
+
+	env := testEnv(301)
+	testLoader := func(conn *fasthttp.RequestCtx) (*TestEnv, Response, error) {
+		return env, nil, nil
+	}
+
+	var recovered any
+	conn := &fasthttp.RequestCtx{}
+	logged := tests.CaptureLog(func() {
+		defer func() { recovered = recover() }()
+		Handler("panic_route", testLoader, func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+			panic("again")
+		})(conn)
+	})
+
+	assert.Equal(t, recovered.(string), "again")
+	assert.True(t, env.released)
+	assert.Equal(t, conn.Response.StatusCode(), 500)
+
+	panicLog, reqLog := parsePanicLog(t, logged)
+	assert.Equal(t, panicLog["panic"], "again")
+	assert.Equal(t, reqLog["_l"], "req")
+}
+
+// The panic entry, followed by the request (or handler error) entry
+func parsePanicLog(t *testing.T, logged string) (map[string]string, map[string]string) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(logged), "\n")
+	assert.Equal(t, len(lines), 2)
+	return log.KvParse(lines[0]), log.KvParse(lines[1])
+}
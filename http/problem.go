@@ -0,0 +1,68 @@
+package http
+
+/*
+Error responses (StaticError, ServerError, SerializationError and
+Validation) are written in one of two formats:
+
+ErrorFormatJSON (the default):
+
+	{"error": "not found", "code": 2008}
+
+ErrorFormatProblem, RFC 9457 Problem Details, as application/problem+json:
+
+	{"type": "about:blank", "title": "Not Found", "status": 404,
+	 "detail": "not found", "code": 2008}
+
+Our code, error_id, invalid (validation errors) and data (see ExposeError)
+are extension members. Since the type is always about:blank, the title is
+the status' reason phrase and the code identifies the specific problem.
+
+StaticErrors render both formats when they're created (which is typically
+at startup, possibly before SetErrorFormat is called).
+*/
+
+import (
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils/json"
+)
+
+type ErrorFormat uint8
+
+const (
+	ErrorFormatJSON ErrorFormat = iota
+	ErrorFormatProblem
+)
+
+const ProblemContentType = "application/problem+json"
+
+var errorFormat = ErrorFormatJSON
+
+// Not thread-safe: should only be called at startup.
+func SetErrorFormat(format ErrorFormat) {
+	errorFormat = format
+}
+
+type problemDetails struct {
+	Type    string         `json:"type"`
+	Title   string         `json:"title"`
+	Status  int            `json:"status"`
+	Detail  string         `json:"detail,omitempty"`
+	Code    int            `json:"code"`
+	ErrorId string         `json:"error_id,omitempty"`
+	Invalid []any          `json:"invalid,omitempty"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+func newProblem(status int, code int, detail string) problemDetails {
+	return problemDetails{
+		Type:   "about:blank",
+		Title:  fasthttp.StatusMessage(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func (p problemDetails) marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
+	"src.goblgobl.com/utils/typed"
+	"src.goblgobl.com/utils/validation"
+)
+
+func Test_Problem_StaticError(t *testing.T) {
+	res := StaticError(404, 9001, "not found")
+
+	conn := problemWrite(t, res, ErrorFormatJSON)
+	assert.Equal(t, string(conn.Response.Header.ContentType()), "application/json")
+	assert.Equal(t, string(conn.Response.Body()), `{"error":"not found","code":9001}`)
+
+	conn = problemWrite(t, res, ErrorFormatProblem)
+	assert.Equal(t, conn.Response.StatusCode(), 404)
+	assert.Equal(t, string(conn.Response.Header.ContentType()), "application/problem+json")
+	assert.Equal(t, string(conn.Response.Body()), `{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","code":9001}`)
+
+	// non-error static responses are unaffected
+	conn = problemWrite(t, preflightResponse, ErrorFormatProblem)
+	assert.Equal(t, conn.Response.StatusCode(), 204)
+	assert.Equal(t, string(conn.Response.Header.ContentType()), "application/json")
+}
+
+func Test_Problem_StaticError_Log(t *testing.T) {
+	SetErrorFormat(ErrorFormatProblem)
+	defer SetErrorFormat(ErrorFormatJSON)
+
+	res := read(StaticError(400, 9002, "bad"))
+	assert.Equal(t, res.log["_code"], "9002")
+	assert.Equal(t, res.log["status"], "400")
+	assert.Equal(t, res.log["res"], strconv.Itoa(len(res.body)))
+}
+
+func Test_Problem_ServerError(t *testing.T) {
+	SetErrorFormat(ErrorFormatProblem)
+	defer SetErrorFormat(ErrorFormatJSON)
+
+	conn := problemWrite(t, ServerError(errors.New("boom"), false), ErrorFormatProblem)
+	assert.Equal(t, conn.Response.StatusCode(), 500)
+	assert.Equal(t, string(conn.Response.Header.ContentType()), "application/problem+json")
+
+	errorId := string(conn.Response.Header.Peek("Error-Id"))
+	body := typedBody(conn)
+	assert.Equal(t, body.String("type"), "about:blank")
+	assert.Equal(t, body.String("title"), "Internal Server Error")
+	assert.Equal(t, body.Int("status"), 500)
+	assert.Equal(t, body.String("detail"), "internal server error")
+	assert.Equal(t, body.Int("code"), 2001)
+	assert.Equal(t, body.String("error_id"), errorId)
+	assert.Equal(t, len(body.Map("data")), 0)
+}
+
+func Test_Problem_ServerError_Exposed(t *testing.T) {
+	SetErrorFormat(ErrorFormatProblem)
+	defer SetErrorFormat(ErrorFormatJSON)
+	ExposeError(9101, "quota exceeded", "limit")
+	defer delete(exposedErrors, 9101)
+
+	err := log.Errf(9101, "quota").String("limit", "10").String("secret", "x")
+	conn := problemWrite(t, ServerError(err, false), ErrorFormatProblem)
+	body := typedBody(conn)
+	assert.Equal(t, body.Int("code"), 9101)
+	assert.Equal(t, body.String("detail"), "quota exceeded")
+	assert.Equal(t, body.Object("data").String("limit"), "10")
+	assert.Equal(t, len(body.Map("data")), 1)
+}
+
+func Test_Problem_SerializationError(t *testing.T) {
+	SetErrorFormat(ErrorFormatProblem)
+	defer SetErrorFormat(ErrorFormatJSON)
+
+	conn := problemWrite(t, OK(make(chan bool)), ErrorFormatProblem)
+	assert.Equal(t, conn.Response.StatusCode(), 500)
+	assert.Equal(t, string(conn.Response.Header.ContentType()), "application/problem+json")
+	body := typedBody(conn)
+	assert.Equal(t, body.Int("code"), 2002)
+	assert.Equal(t, body.String("error_id"), string(conn.Response.Header.Peek("Error-Id")))
+}
+
+func Test_Problem_Validation(t *testing.T) {
+	SetErrorFormat(ErrorFormatProblem)
+	defer SetErrorFormat(ErrorFormatJSON)
+
+	vc := validation.NewContext[any](5)
+	validation.Object[any]().
+		Field("name", validation.String[any]().Required()).
+		Validate(map[string]any{}, vc)
+
+	conn := problemWrite(t, Validation(vc), ErrorFormatProblem)
+	assert.Equal(t, conn.Response.StatusCode(), 400)
+	assert.Equal(t, string(conn.Response.Header.ContentType()), "application/problem+json")
+
+	body := typedBody(conn)
+	assert.Equal(t, body.String("title"), "Bad Request")
+	assert.Equal(t, body.String("detail"), "invalid data")
+	assert.Equal(t, body.Int("status"), 400)
+	assert.Equal(t, body.Int("code"), 2004)
+	invalid := body.Objects("invalid")
+	assert.Equal(t, len(invalid), 1)
+	assert.Equal(t, invalid[0].String("field"), "name")
+}
+
+func problemWrite(t *testing.T, res Response, format ErrorFormat) *fasthttp.RequestCtx {
+	t.Helper()
+	SetErrorFormat(format)
+	defer SetErrorFormat(ErrorFormatJSON)
+
+	conn := &fasthttp.RequestCtx{}
+	conn.Response.Header.SetContentType("application/json")
+	res.Write(conn, log.Noop{})
+	return conn
+}
+
+func typedBody(conn *fasthttp.RequestCtx) typed.Typed {
+	return typed.Must(conn.Response.Body())
+}
@@ -0,0 +1,268 @@
+package http
+
+/*
+In-memory rate limiting, using GCRA (the generic cell rate algorithm, which
+behaves like a token bucket but only needs to store a single timestamp per
+key). Keys are spread across shards (like concurrent.Map), each with its own
+lock.
+
+The RateLimit middleware limits requests by a key extracted from the request
+(e.g. the client's IP or API key). Every limited request gets RateLimit-Limit,
+RateLimit-Remaining and RateLimit-Reset headers. Throttled requests get
+TooManyRequests with a Retry-After header, and are logged with a hash of
+the key (rl_key).
+
+A key's state is dropped once its bucket is full again (it has been idle
+long enough that it no longer affects the outcome), by a background
+goroutine which runs every evictInterval.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/log"
+)
+
+const rateLimitShards = 64
+
+var (
+	TooManyRequests = StaticError(429, utils.RES_RATE_LIMITED, "too many requests")
+
+	retryAfterHeader         = []byte("Retry-After")
+	rateLimitLimitHeader     = []byte("RateLimit-Limit")
+	rateLimitRemainingHeader = []byte("RateLimit-Remaining")
+	rateLimitResetHeader     = []byte("RateLimit-Reset")
+)
+
+// Extracts the key to limit a request by. An empty key means the request
+// isn't limited.
+type RateLimitKey func(conn *fasthttp.RequestCtx) string
+
+// Limits by the client's IP address
+func RateLimitByIP(conn *fasthttp.RequestCtx) string {
+	return conn.RemoteIP().String()
+}
+
+// Limits by the value of a request header (e.g. an API key). Requests
+// without the header aren't limited.
+func RateLimitByHeader(name string) RateLimitKey {
+	header := []byte(name)
+	return func(conn *fasthttp.RequestCtx) string {
+		return string(conn.Request.Header.PeekBytes(header))
+	}
+}
+
+type RateLimiter struct {
+	shards [rateLimitShards]*rateLimitShard
+
+	// time between requests at the sustained rate
+	interval time.Duration
+
+	// how far ahead of now a key's theoretical arrival time can be
+	// (interval * burst)
+	tolerance time.Duration
+
+	// requests allowed per period (the RateLimit-Limit header)
+	limit int
+
+	burst int
+
+	// closed by Close to stop the eviction goroutine
+	stop chan struct{}
+
+	// times are measured relative to this (which keeps them monotonic)
+	epoch time.Time
+
+	// swappable for tests
+	now func() time.Time
+}
+
+type rateLimitShard struct {
+	sync.Mutex
+
+	// key => theoretical arrival time of the key's next request
+	lookup map[string]time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed bool
+
+	// requests which could be made right now
+	Remaining int
+
+	// how long until a request is allowed (0 when Allowed)
+	RetryAfter time.Duration
+
+	// how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Allows limit requests per period, with bursts of up to burst requests
+// (at least 1). evictInterval is how often idle keys are removed (0 never
+// removes them). Panics if limit or period isn't positive (limiters are
+// meant to be created at startup).
+func NewRateLimiter(limit int, period time.Duration, burst int, evictInterval time.Duration) *RateLimiter {
+	if limit <= 0 {
+		panic("rate limiter limit must be positive: " + strconv.Itoa(limit))
+	}
+	if period <= 0 {
+		panic("rate limiter period must be positive: " + period.String())
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	interval := period / time.Duration(limit)
+	if interval == 0 {
+		panic("rate limiter period is too short for its limit: " + period.String())
+	}
+
+	l := &RateLimiter{
+		limit:     limit,
+		burst:     burst,
+		interval:  interval,
+		tolerance: interval * time.Duration(burst),
+		stop:      make(chan struct{}),
+		epoch:     time.Now(),
+		now:       time.Now,
+	}
+	for i := range l.shards {
+		l.shards[i] = &rateLimitShard{lookup: make(map[string]time.Duration)}
+	}
+
+	if evictInterval > 0 {
+		go l.evictEvery(evictInterval)
+	}
+	return l
+}
+
+// Records a request for key, if it's allowed
+func (l *RateLimiter) Allow(key string) RateLimitResult {
+	now := l.now().Sub(l.epoch)
+	interval := l.interval
+
+	s := l.shard(key)
+	s.Lock()
+	defer s.Unlock()
+
+	tat, exists := s.lookup[key]
+	if !exists || tat < now {
+		tat = now
+	}
+
+	next := tat + interval
+	allowAt := next - l.tolerance
+	if now < allowAt {
+		return RateLimitResult{
+			RetryAfter: allowAt - now,
+			Reset:      tat - now,
+		}
+	}
+
+	s.lookup[key] = next
+	return RateLimitResult{
+		Allowed:   true,
+		Remaining: int((now - allowAt) / interval),
+		Reset:     next - now,
+	}
+}
+
+// Stops the eviction goroutine
+func (l *RateLimiter) Close() {
+	close(l.stop)
+}
+
+// The number of keys currently tracked
+func (l *RateLimiter) Len() int {
+	n := 0
+	for _, s := range l.shards {
+		s.Lock()
+		n += len(s.lookup)
+		s.Unlock()
+	}
+	return n
+}
+
+func (l *RateLimiter) evictEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.evict()
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Removes keys whose bucket is full (their state is no different than
+// that of a new key)
+func (l *RateLimiter) evict() {
+	now := l.now().Sub(l.epoch)
+	for _, s := range l.shards {
+		s.Lock()
+		for key, tat := range s.lookup {
+			if tat <= now {
+				delete(s.lookup, key)
+			}
+		}
+		s.Unlock()
+	}
+}
+
+func (l *RateLimiter) shard(key string) *rateLimitShard {
+	var h uint32
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return l.shards[h%rateLimitShards]
+}
+
+// Limits requests using limiter, by the key extracted from the request
+func RateLimit[T Env](limiter *RateLimiter, key RateLimitKey) Middleware[T] {
+	limit := strconv.Itoa(limiter.limit)
+	return func(next HandlerFunc[T]) HandlerFunc[T] {
+		return func(conn *fasthttp.RequestCtx, env T) (Response, error) {
+			k := key(conn)
+			if k == "" {
+				return next(conn, env)
+			}
+
+			result := limiter.Allow(k)
+
+			header := &conn.Response.Header
+			header.SetBytesK(rateLimitLimitHeader, limit)
+			header.SetBytesK(rateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+			header.SetBytesK(rateLimitResetHeader, strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				header.SetBytesK(retryAfterHeader, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				return throttledResponse{key: k}, nil
+			}
+			return next(conn, env)
+		}
+	}
+}
+
+// TooManyRequests, with the (hashed) key added to the log
+type throttledResponse struct {
+	key string
+}
+
+func (r throttledResponse) Write(conn *fasthttp.RequestCtx, logger log.Logger) log.Logger {
+	sum := sha256.Sum256([]byte(r.key))
+	var hashed [16]byte
+	hex.Encode(hashed[:], sum[:8])
+	return TooManyRequests.Write(conn, logger).String("rl_key", utils.B2S(hashed[:]))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
+)
+
+func Test_RateLimiter_Burst(t *testing.T) {
+	l, clock := testRateLimiter(10, time.Second, 3)
+
+	for i := 2; i >= 0; i-- {
+		r := l.Allow("a")
+		assert.True(t, r.Allowed)
+		assert.Equal(t, r.Remaining, i)
+	}
+
+	r := l.Allow("a")
+	assert.False(t, r.Allowed)
+	assert.Equal(t, r.Remaining, 0)
+	assert.Equal(t, r.RetryAfter, 100*time.Millisecond)
+	assert.Equal(t, r.Reset, 300*time.Millisecond)
+
+	// other keys are independent
+	assert.True(t, l.Allow("b").Allowed)
+
+	*clock = clock.Add(50 * time.Millisecond)
+	r = l.Allow("a")
+	assert.False(t, r.Allowed)
+	assert.Equal(t, r.RetryAfter, 50*time.Millisecond)
+
+	*clock = clock.Add(50 * time.Millisecond)
+	r = l.Allow("a")
+	assert.True(t, r.Allowed)
+	assert.Equal(t, r.Remaining, 0)
+	assert.False(t, l.Allow("a").Allowed)
+}
+
+func Test_RateLimiter_Refills(t *testing.T) {
+	l, clock := testRateLimiter(10, time.Second, 3)
+	for i := 0; i < 3; i++ {
+		l.Allow("a")
+	}
+
+	*clock = clock.Add(time.Hour)
+	r := l.Allow("a")
+	assert.True(t, r.Allowed)
+	assert.Equal(t, r.Remaining, 2)
+	assert.Equal(t, r.Reset, 100*time.Millisecond)
+}
+
+func Test_RateLimiter_Evict(t *testing.T) {
+	l, clock := testRateLimiter(10, time.Second, 3)
+	l.Allow("a")
+	l.Allow("a")
+	l.Allow("b")
+	assert.Equal(t, l.Len(), 2)
+
+	*clock = clock.Add(100 * time.Millisecond)
+	l.evict()
+	assert.Equal(t, l.Len(), 1)
+
+	*clock = clock.Add(100 * time.Millisecond)
+	l.evict()
+	assert.Equal(t, l.Len(), 0)
+}
+
+func Test_RateLimiter_EvictLoop(t *testing.T) {
+	l := NewRateLimiter(1000, time.Second, 1, time.Millisecond)
+	defer l.Close()
+
+	l.Allow("a")
+	for i := 0; i < 100 && l.Len() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, l.Len(), 0)
+}
+
+func Test_NewRateLimiter_Invalid(t *testing.T) {
+	assertPanics(t, func() { NewRateLimiter(0, time.Second, 1, 0) })
+	assertPanics(t, func() { NewRateLimiter(-1, time.Second, 1, 0) })
+	assertPanics(t, func() { NewRateLimiter(1, 0, 1, 0) })
+	assertPanics(t, func() { NewRateLimiter(10, time.Nanosecond, 1, 0) })
+}
+
+func Test_RateLimit_LimitHeader(t *testing.T) {
+	l, _ := testRateLimiter(100, time.Minute, 5)
+	next := RateLimit[*TestEnv](l, RateLimitByIP)(func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+		return OK(nil), nil
+	})
+
+	conn := &fasthttp.RequestCtx{}
+	next(conn, testEnv(1))
+	assert.Equal(t, string(conn.Response.Header.Peek("RateLimit-Limit")), "100")
+	assert.Equal(t, string(conn.Response.Header.Peek("RateLimit-Remaining")), "4")
+}
+
+func Test_RateLimit_Middleware(t *testing.T) {
+	l, _ := testRateLimiter(1, time.Second, 1)
+	mw := RateLimit[*TestEnv](l, RateLimitByHeader("X-Key"))
+	next := mw(func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+		return OK(nil), nil
+	})
+
+	// no key, not limited
+	conn := &fasthttp.RequestCtx{}
+	res, _ := next(conn, testEnv(1))
+	assert.Equal(t, res.(JSONResponse).Status, 200)
+	assert.Equal(t, len(conn.Response.Header.Peek("RateLimit-Limit")), 0)
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("X-Key", "secret")
+	res, _ = next(conn, testEnv(1))
+	assert.Equal(t, res.(JSONResponse).Status, 200)
+	header := &conn.Response.Header
+	assert.Equal(t, string(header.Peek("RateLimit-Limit")), "1")
+	assert.Equal(t, string(header.Peek("RateLimit-Remaining")), "0")
+	assert.Equal(t, string(header.Peek("RateLimit-Reset")), "1")
+	assert.Equal(t, len(header.Peek("Retry-After")), 0)
+
+	conn = &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("X-Key", "secret")
+	res, _ = next(conn, testEnv(1))
+
+	logger := res.Write(conn, log.Request("test"))
+	defer logger.Release()
+	fields := log.KvParse(string(logger.Bytes()))
+
+	assert.Equal(t, conn.Response.StatusCode(), 429)
+	assertCode(t, conn, 2010)
+	assert.Equal(t, string(conn.Response.Header.Peek("Retry-After")), "1")
+	assert.Equal(t, string(conn.Response.Header.Peek("RateLimit-Remaining")), "0")
+	assert.Equal(t, fields["status"], "429")
+	assert.Equal(t, fields["rl_key"], "2bb80d537b1da3e3")
+}
+
+func Test_RateLimit_Handler(t *testing.T) {
+	l, _ := testRateLimiter(1, time.Second, 1)
+	handler := Handler("limited", func(conn *fasthttp.RequestCtx) (*TestEnv, Response, error) {
+		return testEnv(1), nil, nil
+	}, RateLimit[*TestEnv](l, RateLimitByIP)(func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+		return OK(nil), nil
+	}))
+
+	conn := &fasthttp.RequestCtx{}
+	handler(conn)
+	assert.Equal(t, conn.Response.StatusCode(), 200)
+
+	conn = &fasthttp.RequestCtx{}
+	handler(conn)
+	assert.Equal(t, conn.Response.StatusCode(), 429)
+	assertCode(t, conn, 2010)
+}
+
+func Test_ceilSeconds(t *testing.T) {
+	assert.Equal(t, ceilSeconds(0), 0)
+	assert.Equal(t, ceilSeconds(time.Millisecond), 1)
+	assert.Equal(t, ceilSeconds(time.Second), 1)
+	assert.Equal(t, ceilSeconds(1001*time.Millisecond), 2)
+}
+
+// A limiter (without the eviction goroutine) whose clock only moves when
+// the returned time is changed
+func testRateLimiter(limit int, period time.Duration, burst int) (*RateLimiter, *time.Time) {
+	l := NewRateLimiter(limit, period, burst, 0)
+	clock := l.epoch
+	l.now = func() time.Time { return clock }
+	return l, &clock
+}
@@ -2,9 +2,12 @@ package http
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/buffer"
 	"src.goblgobl.com/utils/log"
 	"src.goblgobl.com/utils/typed"
 	"src.goblgobl.com/utils/validation"
@@ -50,6 +53,54 @@ func Test_OK_InvalidBody(t *testing.T) {
 	assert.Equal(t, res.json.String("error_id"), errorId)
 }
 
+func Test_BufferedOK_NoBody(t *testing.T) {
+	pool := buffer.NewPool(8, 32, 64)
+	res := read(BufferedOK(pool, nil))
+	assert.Equal(t, res.status, 200)
+	assert.Equal(t, len(res.body), 0)
+	assert.Equal(t, res.log["res"], "0")
+	assert.Equal(t, pool.Len(), 8)
+}
+
+func Test_BufferedOK_Body(t *testing.T) {
+	pool := buffer.NewPool(8, 32, 64)
+	r := BufferedOK(pool, map[string]any{"over": 9000})
+	assert.Equal(t, pool.Len(), 7)
+
+	res := read(r)
+	assert.Equal(t, res.status, 200)
+	assert.Equal(t, res.body, `{"over":9000}`)
+	assert.Equal(t, res.log["res"], "13")
+	assert.Equal(t, res.log["status"], "200")
+
+	// the buffer is released once the body stream is read
+	assert.Equal(t, pool.Len(), 8)
+}
+
+func Test_BufferedOK_Grows(t *testing.T) {
+	pool := buffer.NewPool(8, 4, 64)
+	res := read(BufferedOK(pool, map[string]any{"over": 9000}))
+	assert.Equal(t, res.body, `{"over":9000}`)
+}
+
+func Test_BufferedOK_MaxSize(t *testing.T) {
+	pool := buffer.NewPool(8, 4, 10)
+	res := read(BufferedOK(pool, map[string]any{"over": 9000}))
+	assert.Equal(t, res.status, 500)
+	assert.Equal(t, res.json.Int("code"), 2002)
+	assert.Equal(t, res.log["_code"], "2002")
+	assert.Equal(t, res.log["_err"], `"code: 3005 - buffer maximum size"`)
+	assert.Equal(t, pool.Len(), 8)
+}
+
+func Test_BufferedOK_InvalidBody(t *testing.T) {
+	pool := buffer.NewPool(8, 32, 64)
+	res := read(BufferedOK(pool, make(chan bool)))
+	assert.Equal(t, res.status, 500)
+	assert.Equal(t, res.json.Int("code"), 2002)
+	assert.Equal(t, pool.Len(), 8)
+}
+
 func Test_Created_NoBody(t *testing.T) {
 	res := read(Created(nil))
 	assert.Equal(t, res.status, 201)
@@ -133,6 +184,57 @@ func Test_ServerError_FullError(t *testing.T) {
 	assert.Equal(t, res.log["eid"], errorId)
 }
 
+func Test_ServerError_Stack(t *testing.T) {
+	res := read(ServerError(errors.New("an_error1"), false))
+	_, exists := res.log["_stack"]
+	assert.False(t, exists)
+
+	log.Configure(log.Config{Level: "info", Caller: &log.CallerConfig{}})
+	defer log.Configure(log.Config{Level: "info"})
+
+	res = read(ServerError(errors.New("an_error1"), false))
+	assert.True(t, strings.HasPrefix(res.log["_stack"], "http.Test_ServerError_Stack:"))
+}
+
+func Test_ServerError_StructuredError_NotExposed(t *testing.T) {
+	se := log.Err(9002, errors.New("secret")).String("id", "a")
+	res := read(ServerError(se, false))
+	assert.Equal(t, res.status, 500)
+	assert.Equal(t, res.json.Int("code"), 2001)
+	assert.Equal(t, res.json.String("error"), "internal server error")
+	assert.Nil(t, res.json.Object("data"))
+}
+
+func Test_ServerError_StructuredError_Exposed(t *testing.T) {
+	ExposeError(9003, "account is locked", "id", "until")
+	defer delete(exposedErrors, 9003)
+
+	se := log.Err(9003, errors.New("secret")).String("id", "a").String("internal", "x")
+	res := read(ServerError(fmt.Errorf("wrapped(%w)", se), false))
+	assert.Equal(t, res.status, 500)
+	assert.Equal(t, res.json.Int("code"), 9003)
+	assert.Equal(t, res.json.String("error"), "account is locked")
+	assert.Equal(t, len(res.json.String("error_id")), 36)
+
+	data := res.json.Object("data")
+	assert.Equal(t, len(data), 1)
+	assert.Equal(t, data.String("id"), "a")
+
+	assert.Equal(t, res.log["_err"], `"wrapped(code: 9003 - secret)"`)
+	assert.Equal(t, res.log["internal"], "x")
+	assert.Equal(t, res.log["status"], "500")
+}
+
+func Test_ServerError_StructuredError_Exposed_NoData(t *testing.T) {
+	ExposeError(9004, "not allowed")
+	defer delete(exposedErrors, 9004)
+
+	res := read(ServerError(log.Err(9004, errors.New("secret")).String("id", "a"), false))
+	assert.Equal(t, res.json.Int("code"), 9004)
+	assert.Equal(t, res.json.String("error"), "not allowed")
+	assert.Nil(t, res.json.Object("data"))
+}
+
 func Test_Validation(t *testing.T) {
 	rules := validation.Object[any]().
 		Field("field1", validation.String[any]().Required()).
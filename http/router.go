@@ -0,0 +1,351 @@
+package http
+
+/*
+A small radix-tree router. Patterns are made of static text, named params
+(:name, which match a single non-empty path segment) and a trailing
+wildcard (*name, which matches the rest of the path, possibly empty):
+
+	router := http.NewRouter()
+	http.Route(router, "GET", "/users/:id", loadEnv, users.Show)
+	router.NoEnv("GET", "/files/*path", files.Serve)
+	server := fasthttp.Server{Handler: router.Handler}
+
+The pattern is the routeName given to Handler / NoEnvHandler (and thus what
+requests are logged and measured as). Static matches take precedence over
+params, which take precedence over wildcards. Matched params are available
+via RouteParams.
+
+Routes must be registered before the router starts serving requests.
+*/
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/typed"
+)
+
+const paramsUserValue = "_params"
+
+var allowHeader = []byte("Allow")
+
+type Router struct {
+	// method => tree
+	trees map[string]*node
+
+	// methods in registration order (for the Allow header)
+	methods []string
+
+	notFound         fasthttp.RequestHandler
+	methodNotAllowed fasthttp.RequestHandler
+
+	// Written when no route matches the path
+	NotFound Response
+
+	// Written when routes match the path, but not for the request's method
+	MethodNotAllowed Response
+}
+
+func NewRouter() *Router {
+	r := &Router{
+		trees:            make(map[string]*node),
+		NotFound:         StaticNotFound(utils.RES_NOT_FOUND),
+		MethodNotAllowed: StaticError(405, utils.RES_METHOD_NOT_ALLOWED, "method not allowed"),
+	}
+	r.notFound = NoEnvHandler("not_found", func(conn *fasthttp.RequestCtx) (Response, error) {
+		return r.NotFound, nil
+	})
+	r.methodNotAllowed = NoEnvHandler("method_not_allowed", func(conn *fasthttp.RequestCtx) (Response, error) {
+		return r.MethodNotAllowed, nil
+	})
+	return r
+}
+
+// Registers a route with an env (see Handler)
+func Route[T Env](r *Router, method string, pattern string, loadEnv func(ctx *fasthttp.RequestCtx) (T, Response, error), next HandlerFunc[T]) {
+	r.Handle(method, pattern, Handler(pattern, loadEnv, next))
+}
+
+// Registers a route without an env (see NoEnvHandler)
+func (r *Router) NoEnv(method string, pattern string, next func(ctx *fasthttp.RequestCtx) (Response, error)) {
+	r.Handle(method, pattern, NoEnvHandler(pattern, next))
+}
+
+// Registers a raw fasthttp handler. Panics if the pattern is invalid or
+// conflicts with an existing route.
+func (r *Router) Handle(method string, pattern string, handler fasthttp.RequestHandler) {
+	tokens := parsePattern(pattern)
+
+	root := r.trees[method]
+	if root == nil {
+		root = &node{}
+		r.trees[method] = root
+		r.methods = append(r.methods, method)
+	}
+	root.insert(tokens, pattern, handler)
+}
+
+// The fasthttp.RequestHandler to serve
+func (r *Router) Handler(conn *fasthttp.RequestCtx) {
+	path := string(conn.Path())
+
+	if root := r.trees[utils.B2S(conn.Method())]; root != nil {
+		var params Params
+		if n := root.lookup(path, &params); n != nil {
+			if len(params) > 0 {
+				conn.SetUserValue(paramsUserValue, params)
+			}
+			n.handler(conn)
+			return
+		}
+	}
+
+	if allowed := r.allowed(path); allowed != "" {
+		conn.Response.Header.SetBytesK(allowHeader, allowed)
+		r.methodNotAllowed(conn)
+		return
+	}
+	r.notFound(conn)
+}
+
+// Comma-separated list of the methods which have a route matching path
+func (r *Router) allowed(path string) string {
+	var allowed string
+	var params Params
+	for _, method := range r.methods {
+		params = params[:0]
+		if r.trees[method].lookup(path, &params) == nil {
+			continue
+		}
+		if allowed == "" {
+			allowed = method
+		} else {
+			allowed += ", " + method
+		}
+	}
+	return allowed
+}
+
+type Param struct {
+	Key   string
+	Value string
+}
+
+type Params []Param
+
+// The value of the param, or "" if there's no such param
+func (p Params) Get(key string) string {
+	for _, param := range p {
+		if param.Key == key {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+func (p Params) Typed() typed.Typed {
+	t := make(typed.Typed, len(p))
+	for _, param := range p {
+		t[param.Key] = param.Value
+	}
+	return t
+}
+
+// The params matched by the Router for this request
+func RouteParams(conn *fasthttp.RequestCtx) Params {
+	params, _ := conn.UserValue(paramsUserValue).(Params)
+	return params
+}
+
+type tokenKind uint8
+
+const (
+	tokenStatic tokenKind = iota
+	tokenParam
+	tokenWildcard
+)
+
+type token struct {
+	kind tokenKind
+
+	// the static text or the param's name
+	value string
+}
+
+// "/users/:id/files/*path" => [static "/users/", param "id", static "/files/", wildcard "path"]
+func parsePattern(pattern string) []token {
+	if pattern == "" || pattern[0] != '/' {
+		panic("route pattern must start with /: " + pattern)
+	}
+
+	var tokens []token
+	for rest := pattern; rest != ""; {
+		i := strings.IndexAny(rest, ":*")
+		if i == -1 {
+			tokens = append(tokens, token{kind: tokenStatic, value: rest})
+			break
+		}
+		if i > 0 {
+			tokens = append(tokens, token{kind: tokenStatic, value: rest[:i]})
+		}
+		if i == 0 || rest[i-1] != '/' {
+			panic("route params must start a path segment: " + pattern)
+		}
+
+		kind := tokenParam
+		if rest[i] == '*' {
+			kind = tokenWildcard
+		}
+
+		rest = rest[i+1:]
+		end := strings.IndexByte(rest, '/')
+		if end == -1 {
+			end = len(rest)
+		}
+		name := rest[:end]
+		if name == "" || strings.ContainsAny(name, ":*") {
+			panic("route params must have a valid name: " + pattern)
+		}
+		if kind == tokenWildcard && end != len(rest) {
+			panic("route wildcards must be at the end: " + pattern)
+		}
+
+		tokens = append(tokens, token{kind: kind, value: name})
+		rest = rest[end:]
+	}
+	return tokens
+}
+
+type node struct {
+	// for static nodes, the (compressed) text this node matches. For param
+	// and wildcard nodes, the param's name.
+	path string
+
+	// static children, each starting with a different byte
+	children []*node
+
+	param    *node
+	wildcard *node
+
+	// set when a route ends at this node
+	pattern string
+	handler fasthttp.RequestHandler
+}
+
+func (n *node) insert(tokens []token, pattern string, handler fasthttp.RequestHandler) {
+	if len(tokens) == 0 {
+		if n.pattern != "" {
+			panic("route " + pattern + " conflicts with " + n.pattern)
+		}
+		n.handler = handler
+		n.pattern = pattern
+		return
+	}
+
+	t := tokens[0]
+	switch t.kind {
+	case tokenStatic:
+		n.insertStatic(t.value, tokens[1:], pattern, handler)
+	case tokenParam:
+		if n.param == nil {
+			n.param = &node{path: t.value}
+		} else if n.param.path != t.value {
+			panic("route " + pattern + " param :" + t.value + " conflicts with :" + n.param.path)
+		}
+		n.param.insert(tokens[1:], pattern, handler)
+	case tokenWildcard:
+		if n.wildcard == nil {
+			n.wildcard = &node{path: t.value}
+		} else if n.wildcard.path != t.value {
+			panic("route " + pattern + " wildcard *" + t.value + " conflicts with *" + n.wildcard.path)
+		}
+		n.wildcard.insert(nil, pattern, handler)
+	}
+}
+
+func (n *node) insertStatic(path string, rest []token, pattern string, handler fasthttp.RequestHandler) {
+	for i, child := range n.children {
+		if child.path[0] != path[0] {
+			continue
+		}
+
+		l := commonPrefix(child.path, path)
+		if l < len(child.path) {
+			// split the child: the shared prefix becomes the parent of what's
+			// left of the child
+			parent := &node{path: child.path[:l], children: []*node{child}}
+			child.path = child.path[l:]
+			n.children[i] = parent
+			child = parent
+		}
+
+		if l == len(path) {
+			child.insert(rest, pattern, handler)
+		} else {
+			child.insertStatic(path[l:], rest, pattern, handler)
+		}
+		return
+	}
+
+	child := &node{path: path}
+	n.children = append(n.children, child)
+	child.insert(rest, pattern, handler)
+}
+
+// path is what's left to match after this node. Matched params are
+// appended to params (and removed again when backtracking).
+func (n *node) lookup(path string, params *Params) *node {
+	if path == "" && n.pattern != "" {
+		return n
+	}
+
+	if path != "" {
+		for _, child := range n.children {
+			if child.path[0] != path[0] {
+				continue
+			}
+			if strings.HasPrefix(path, child.path) {
+				if found := child.lookup(path[len(child.path):], params); found != nil {
+					return found
+				}
+			}
+			// only one child can start with this byte
+			break
+		}
+
+		if p := n.param; p != nil {
+			end := strings.IndexByte(path, '/')
+			if end == -1 {
+				end = len(path)
+			}
+			if end > 0 {
+				l := len(*params)
+				*params = append(*params, Param{Key: p.path, Value: path[:end]})
+				if found := p.lookup(path[end:], params); found != nil {
+					return found
+				}
+				*params = (*params)[:l]
+			}
+		}
+	}
+
+	if w := n.wildcard; w != nil {
+		*params = append(*params, Param{Key: w.path, Value: path})
+		return w
+	}
+	return nil
+}
+
+func commonPrefix(a string, b string) int {
+	l := len(a)
+	if len(b) < l {
+		l = len(b)
+	}
+	for i := 0; i < l; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return l
+}
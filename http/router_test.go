@@ -0,0 +1,193 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
+)
+
+func Test_Router_Static(t *testing.T) {
+	r := testRouter("/", "/users", "/users/new", "/user", "/users/new/x")
+	assertRoute(t, r, "GET", "/", "/")
+	assertRoute(t, r, "GET", "/users", "/users")
+	assertRoute(t, r, "GET", "/user", "/user")
+	assertRoute(t, r, "GET", "/users/new", "/users/new")
+	assertRoute(t, r, "GET", "/users/new/x", "/users/new/x")
+	assertRoute(t, r, "GET", "/users/", "")
+	assertRoute(t, r, "GET", "/use", "")
+	assertRoute(t, r, "GET", "/users/new/", "")
+	assertRoute(t, r, "GET", "/other", "")
+}
+
+func Test_Router_Params(t *testing.T) {
+	r := testRouter("/users/:id", "/users/:id/posts/:post", "/users/new", "/teams/:team/users/:id")
+
+	params := assertRoute(t, r, "GET", "/users/leto", "/users/:id")
+	assert.Equal(t, params.Get("id"), "leto")
+	assert.Equal(t, params.Get("nope"), "")
+
+	params = assertRoute(t, r, "GET", "/users/new", "/users/new")
+	assert.Equal(t, len(params), 0)
+
+	params = assertRoute(t, r, "GET", "/users/newer", "/users/:id")
+	assert.Equal(t, params.Get("id"), "newer")
+
+	params = assertRoute(t, r, "GET", "/users/9001/posts/3", "/users/:id/posts/:post")
+	assert.Equal(t, params.Get("id"), "9001")
+	assert.Equal(t, params.Get("post"), "3")
+	assert.Equal(t, params.Typed().Int("post"), 3)
+
+	params = assertRoute(t, r, "GET", "/teams/a/users/b", "/teams/:team/users/:id")
+	assert.Equal(t, params.Get("team"), "a")
+	assert.Equal(t, params.Get("id"), "b")
+
+	assertRoute(t, r, "GET", "/users/", "")
+	assertRoute(t, r, "GET", "/users/9001/posts", "")
+	assertRoute(t, r, "GET", "/users/9001/posts/", "")
+}
+
+func Test_Router_Backtracking(t *testing.T) {
+	r := testRouter("/users/new/edit", "/users/:id/delete")
+
+	params := assertRoute(t, r, "GET", "/users/new/delete", "/users/:id/delete")
+	assert.Equal(t, params.Get("id"), "new")
+	assert.Equal(t, len(params), 1)
+	assertRoute(t, r, "GET", "/users/new/edit", "/users/new/edit")
+}
+
+func Test_Router_Wildcard(t *testing.T) {
+	r := testRouter("/files/*path", "/files/index", "/assets/:kind/*rest")
+
+	params := assertRoute(t, r, "GET", "/files/a/b/c.txt", "/files/*path")
+	assert.Equal(t, params.Get("path"), "a/b/c.txt")
+
+	params = assertRoute(t, r, "GET", "/files/", "/files/*path")
+	assert.Equal(t, params.Get("path"), "")
+
+	assertRoute(t, r, "GET", "/files/index", "/files/index")
+	params = assertRoute(t, r, "GET", "/files/indexes", "/files/*path")
+	assert.Equal(t, params.Get("path"), "indexes")
+
+	params = assertRoute(t, r, "GET", "/assets/img/x/y.png", "/assets/:kind/*rest")
+	assert.Equal(t, params.Get("kind"), "img")
+	assert.Equal(t, params.Get("rest"), "x/y.png")
+
+	assertRoute(t, r, "GET", "/files", "")
+}
+
+func Test_Router_InvalidPatterns(t *testing.T) {
+	for _, pattern := range []string{"", "users", "/users:id", "/users/:", "/files/*", "/files/*path/x", "/a/:b:c"} {
+		assertPanics(t, func() { NewRouter().Handle("GET", pattern, nil) })
+	}
+
+	r := NewRouter()
+	r.Handle("GET", "/users/:id", nil)
+	assertPanics(t, func() { r.Handle("GET", "/users/:id", nil) })
+	assertPanics(t, func() { r.Handle("GET", "/users/:name", nil) })
+	r.Handle("POST", "/users/:id", nil)
+}
+
+func Test_Router_NotFound(t *testing.T) {
+	r := testRouter("/users")
+
+	conn := routerConn("GET", "/teams")
+	logged := tests.CaptureLog(func() { r.Handler(conn) })
+
+	assert.Equal(t, conn.Response.StatusCode(), 404)
+	assertCode(t, conn, 2008)
+	reqLog := log.KvParse(logged)
+	assert.Equal(t, reqLog["_c"], "not_found")
+	assert.Equal(t, reqLog["status"], "404")
+}
+
+func Test_Router_MethodNotAllowed(t *testing.T) {
+	r := testRouter("/users/:id")
+	r.Handle("DELETE", "/users/:id", nil)
+	r.Handle("PUT", "/teams/:id", nil)
+
+	conn := routerConn("POST", "/users/3")
+	logged := tests.CaptureLog(func() { r.Handler(conn) })
+
+	assert.Equal(t, conn.Response.StatusCode(), 405)
+	assertCode(t, conn, 2009)
+	assert.Equal(t, string(conn.Response.Header.Peek("Allow")), "GET, DELETE")
+	assert.Equal(t, log.KvParse(logged)["_c"], "method_not_allowed")
+}
+
+func Test_Router_CustomNotFound(t *testing.T) {
+	r := NewRouter()
+	r.NotFound = StaticError(404, 9001, "nope")
+
+	conn := routerConn("GET", "/")
+	tests.CaptureLog(func() { r.Handler(conn) })
+	assertCode(t, conn, 9001)
+}
+
+func Test_Router_Route_RouteName(t *testing.T) {
+	r := NewRouter()
+	testLoader := func(conn *fasthttp.RequestCtx) (*TestEnv, Response, error) {
+		return testEnv(1), nil, nil
+	}
+	Route(r, "GET", "/users/:id", testLoader, func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+		return OK(map[string]any{"id": RouteParams(conn).Get("id")}), nil
+	})
+	r.NoEnv("GET", "/health", func(conn *fasthttp.RequestCtx) (Response, error) {
+		return OK(nil), nil
+	})
+
+	conn := routerConn("GET", "/users/leto")
+	logged := tests.CaptureLog(func() { r.Handler(conn) })
+	assert.Equal(t, string(conn.Response.Body()), `{"id":"leto"}`)
+	assert.Equal(t, log.KvParse(logged)["_c"], "/users/:id")
+
+	conn = routerConn("GET", "/health")
+	logged = tests.CaptureLog(func() { r.Handler(conn) })
+	assert.Equal(t, conn.Response.StatusCode(), 200)
+	assert.Equal(t, log.KvParse(logged)["_c"], "/health")
+}
+
+func testRouter(patterns ...string) *Router {
+	r := NewRouter()
+	for _, pattern := range patterns {
+		pattern := pattern
+		r.Handle("GET", pattern, func(conn *fasthttp.RequestCtx) {
+			conn.SetUserValue("pattern", pattern)
+		})
+	}
+	return r
+}
+
+func routerConn(method string, path string) *fasthttp.RequestCtx {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.SetMethod(method)
+	conn.Request.SetRequestURI(path)
+	return conn
+}
+
+// expected is the pattern which should match ("" for a 404)
+func assertRoute(t *testing.T, r *Router, method string, path string, expected string) Params {
+	t.Helper()
+	conn := routerConn(method, path)
+	tests.CaptureLog(func() { r.Handler(conn) })
+
+	matched, _ := conn.UserValue("pattern").(string)
+	assert.Equal(t, matched, expected)
+	if expected == "" {
+		assert.Equal(t, conn.Response.StatusCode(), 404)
+	}
+	return RouteParams(conn)
+}
+
+func assertPanics(t *testing.T, fn func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	fn()
+}
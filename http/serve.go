@@ -0,0 +1,133 @@
+package http
+
+/*
+Runs a fasthttp.Server until the process is told to stop:
+
+	err := http.Serve(&fasthttp.Server{
+		Handler:     router.Handler,
+		IdleTimeout: 30 * time.Second,
+	}, http.ServeConfig{
+		Address: "127.0.0.1:5200",
+		Release: []utils.Releasable{db},
+	})
+
+On SIGTERM (or SIGINT), the listener is closed and in-flight requests are
+given up to ShutdownTimeout to finish. The Releasables are then released (in
+order) and the log is flushed. Since fasthttp doesn't close keepalive
+connections which are in the middle of a request, servers should set an
+IdleTimeout (and possibly CloseOnShutdown).
+*/
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/log"
+)
+
+type ServeConfig struct {
+	// The address to listen on, ignored if Listener is set
+	Address string
+
+	// An already-open listener to serve on
+	Listener net.Listener
+
+	// How long to wait for in-flight requests to finish (defaults to 10s)
+	ShutdownTimeout time.Duration
+
+	// The signals which trigger a shutdown (defaults to SIGTERM and SIGINT)
+	Signals []os.Signal
+
+	// Released, in order, after the server has stopped (e.g. pg.DB, sqlite.Conn)
+	Release []utils.Releasable
+}
+
+// Blocks until the server is shutdown (returns nil) or fails (returns the
+// error). Either way, config.Release is released and the log is flushed.
+func Serve(server *fasthttp.Server, config ServeConfig) error {
+	ln := config.Listener
+	if ln == nil {
+		var err error
+		ln, err = net.Listen("tcp", config.Address)
+		if err != nil {
+			err = log.Err(utils.ERR_HTTP_LISTEN, err).String("address", config.Address)
+			log.Error("http_listen").Err(err).Log()
+			release(config.Release)
+			log.Close()
+			return err
+		}
+	}
+
+	signals := config.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, signals...)
+	defer signal.Stop(stop)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(ln)
+	}()
+	log.Info("http_listen").String("address", ln.Addr().String()).Log()
+
+	var err error
+	select {
+	case sig := <-stop:
+		timeout := config.ShutdownTimeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		err = shutdown(server, sig, timeout)
+		<-served
+	case err = <-served:
+		if err != nil {
+			err = log.Err(utils.ERR_HTTP_SERVE, err)
+			log.Error("http_serve").Err(err).Log()
+		}
+	}
+
+	release(config.Release)
+	log.Info("http_stopped").Log()
+	log.Close()
+	return err
+}
+
+// Stops accepting connections and waits (up to timeout) for in-flight
+// requests to finish
+func shutdown(server *fasthttp.Server, sig os.Signal, timeout time.Duration) error {
+	log.Info("http_shutdown").
+		String("signal", sig.String()).
+		Duration("timeout", timeout).
+		Log()
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.ShutdownWithContext(ctx); err != nil {
+		err = log.Err(utils.ERR_HTTP_SHUTDOWN, err)
+		log.Error("http_shutdown").Err(err).Log()
+		return err
+	}
+
+	elapsed := time.Since(start)
+	log.Info("http_drained").Float("ms", float64(elapsed.Microseconds())/1000).Log()
+	return nil
+}
+
+func release(releasables []utils.Releasable) {
+	for _, r := range releasables {
+		r.Release()
+	}
+	if len(releasables) > 0 {
+		log.Info("http_released").Int("count", len(releasables)).Log()
+	}
+}
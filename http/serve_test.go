@@ -0,0 +1,167 @@
+package http
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/log"
+)
+
+func Test_Serve_ListenError(t *testing.T) {
+	r := &testReleasable{}
+
+	var err error
+	var logged string
+	tests.CaptureLog(func() {
+		// the log is flushed before Serve returns, even when it can't listen
+		out := &strings.Builder{}
+		log.Out = out
+		assert.Nil(t, log.Configure(log.Config{Level: "info", Async: &log.AsyncConfig{}}))
+		defer log.Configure(log.Config{Level: "info"})
+
+		err = Serve(&fasthttp.Server{}, ServeConfig{
+			Address: "256.0.0.1:0",
+			Release: []utils.Releasable{r},
+		})
+		logged = out.String()
+	})
+
+	var se *log.StructuredError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, se.Code, utils.ERR_HTTP_LISTEN)
+	assert.Equal(t, r.released, 1)
+
+	lines := serveLogLines(logged)
+	assert.Equal(t, lines[1]["_l"], "error")
+	assert.Equal(t, lines[1]["_c"], "http_listen")
+	assert.Equal(t, lines[1]["_code"], "3010")
+	assert.Equal(t, lines[2]["_c"], "http_released")
+}
+
+func Test_Serve_WaitsForInFlight(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	handled := make(chan int, 1)
+
+	server := &fasthttp.Server{Handler: func(conn *fasthttp.RequestCtx) {
+		close(started)
+		<-finish
+		conn.SetBodyString("done")
+	}}
+
+	ln := testListener(t)
+	r1, r2 := &testReleasable{}, &testReleasable{}
+
+	var err error
+	logged := tests.CaptureLog(func() {
+		go func() {
+			status, _, _ := fasthttp.Get(nil, "http://"+ln.Addr().String()+"/")
+			handled <- status
+		}()
+
+		go func() {
+			<-started
+			sendSignal(t)
+			// give the shutdown a chance to (wrongly) skip the in-flight request
+			time.Sleep(20 * time.Millisecond)
+			assert.Equal(t, r1.released, 0)
+			close(finish)
+		}()
+
+		err = Serve(server, ServeConfig{
+			Listener: ln,
+			Signals:  []os.Signal{syscall.SIGUSR1},
+			Release:  []utils.Releasable{r1, r2},
+		})
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, <-handled, 200)
+	assert.Equal(t, r1.released, 1)
+	assert.Equal(t, r2.released, 1)
+
+	lines := serveLogLines(logged)
+	assert.Equal(t, lines[0]["_c"], "http_listen")
+	assert.Equal(t, lines[0]["address"], ln.Addr().String())
+	assert.Equal(t, lines[1]["_c"], "http_shutdown")
+	assert.Equal(t, lines[1]["signal"], `"user defined signal 1"`)
+	assert.Equal(t, lines[2]["_c"], "http_drained")
+	assert.Equal(t, lines[3]["_c"], "http_released")
+	assert.Equal(t, lines[3]["count"], "2")
+	assert.Equal(t, lines[4]["_c"], "http_stopped")
+}
+
+func Test_Serve_ShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	defer close(finish)
+
+	server := &fasthttp.Server{Handler: func(conn *fasthttp.RequestCtx) {
+		close(started)
+		<-finish
+	}}
+
+	ln := testListener(t)
+	r := &testReleasable{}
+
+	var err error
+	logged := tests.CaptureLog(func() {
+		go fasthttp.Get(nil, "http://"+ln.Addr().String()+"/")
+		go func() {
+			<-started
+			sendSignal(t)
+		}()
+
+		err = Serve(server, ServeConfig{
+			Listener:        ln,
+			ShutdownTimeout: 10 * time.Millisecond,
+			Signals:         []os.Signal{syscall.SIGUSR1},
+			Release:         []utils.Releasable{r},
+		})
+	})
+
+	var se *log.StructuredError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, se.Code, utils.ERR_HTTP_SHUTDOWN)
+	assert.Equal(t, r.released, 1)
+
+	lines := serveLogLines(logged)
+	assert.Equal(t, lines[2]["_l"], "error")
+	assert.Equal(t, lines[2]["_c"], "http_shutdown")
+	assert.Equal(t, lines[2]["_code"], "3012")
+}
+
+type testReleasable struct {
+	released int
+}
+
+func (r *testReleasable) Release() {
+	r.released += 1
+}
+
+func testListener(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	return ln
+}
+
+func sendSignal(t *testing.T) {
+	assert.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+}
+
+func serveLogLines(logged string) []map[string]string {
+	var lines []map[string]string
+	for _, line := range strings.Split(strings.TrimSpace(logged), "\n") {
+		lines = append(lines, log.KvParse(line))
+	}
+	return lines
+}
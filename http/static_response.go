@@ -22,12 +22,34 @@ type StaticResponse struct {
 	body    []byte
 	logData log.Field
 	status  int
+
+	// body, precompressed (see compress.go)
+	encoded encodedBodies
+
+	// the same response as Problem Details (see problem.go), nil for
+	// responses which aren't errors
+	problem *StaticResponse
 }
 
 func (r StaticResponse) Write(conn *fasthttp.RequestCtx, logger log.Logger) log.Logger {
+	if problem := r.problem; problem != nil && errorFormat == ErrorFormatProblem {
+		r = *problem
+		conn.SetContentType(ProblemContentType)
+	}
+
 	conn.SetStatusCode(r.status)
+	logger.Field(r.logData)
+
+	if encoding := compression.responseEncoding(conn, len(r.body)); encoding != EncodingIdentity {
+		if body := r.encoded[encoding]; body != nil {
+			conn.Response.Header.SetContentEncoding(encoding.String())
+			conn.SetBody(body)
+			return logEncoded(logger, encoding, len(body))
+		}
+	}
+
 	conn.SetBody(r.body)
-	return logger.Field(r.logData)
+	return logger
 }
 
 func StaticError(status int, code int, error string) StaticResponse {
@@ -44,6 +66,18 @@ func StaticError(status int, code int, error string) StaticResponse {
 		panic(err)
 	}
 
+	problemBody, err := newProblem(status, code, error).marshal()
+	if err != nil {
+		panic(err)
+	}
+
+	problem := newStaticError(status, code, problemBody)
+	res := newStaticError(status, code, body)
+	res.problem = &problem
+	return res
+}
+
+func newStaticError(status int, code int, body []byte) StaticResponse {
 	logData := log.NewField().
 		Int("_code", code).
 		Int("status", status).
@@ -54,6 +88,7 @@ func StaticError(status int, code int, error string) StaticResponse {
 		body:    body,
 		status:  status,
 		logData: logData,
+		encoded: newEncodedBodies(body),
 	}
 }
 
@@ -0,0 +1,183 @@
+package http
+
+/*
+W3C Trace Context (https://www.w3.org/TR/trace-context/) support. The
+incoming traceparent header is parsed, and the request becomes a new span
+(with a new span id) within the caller's trace. Without a valid header, the
+request has no trace, unless GenerateTraces is enabled, in which case a new
+trace is started.
+
+Handler and NoEnvHandler load the trace before calling the env loader,
+making it available via GetTrace. Handler stamps it into the request's log
+entry. Envs which implement TracedEnv are instead given the trace as soon as
+they're loaded, to stamp into their MultiUse logger (via Trace.Log), so that
+every entry logged for the request includes the trace_id and span_id. The
+traceparent response header is set, alongside the RequestId header.
+*/
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/log"
+)
+
+const traceUserValue = "_trace"
+
+var (
+	// Whether requests without a (valid) traceparent header start a new trace.
+	// When false (the default), such requests have a zero Trace. Should only
+	// be changed at startup.
+	GenerateTraces = false
+
+	traceparentHeader = []byte("traceparent")
+)
+
+// Implemented by envs which stamp the trace into their MultiUse logger,
+// typically:
+//
+//	func (e *Env) SetTrace(t http.Trace) {
+//		t.Log(e.logger).MultiUse()
+//	}
+//
+// Called by Handler once the env is loaded (only for non-zero traces).
+type TracedEnv interface {
+	SetTrace(t Trace)
+}
+
+type Trace struct {
+	TraceId [16]byte
+
+	// the span of this request
+	SpanId [8]byte
+
+	// the span of the caller (zero when we started the trace)
+	ParentId [8]byte
+
+	Flags byte
+}
+
+// Parses a traceparent header value (version 00, or a future version,
+// which must begin with the same fields). Returns false if value is invalid.
+func ParseTraceparent(value []byte) (Trace, bool) {
+	var t Trace
+
+	// 00-{32 hex trace id}-{16 hex parent id}-{2 hex flags}
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return t, false
+	}
+
+	var version [1]byte
+	if !decodeHex(version[:], value[:2]) || version[0] == 0xff {
+		return t, false
+	}
+	if version[0] == 0 && len(value) != 55 {
+		return t, false
+	}
+	if len(value) > 55 && value[55] != '-' {
+		return t, false
+	}
+
+	var flags [1]byte
+	if !decodeHex(t.TraceId[:], value[3:35]) || !decodeHex(t.ParentId[:], value[36:52]) || !decodeHex(flags[:], value[53:55]) {
+		return t, false
+	}
+	if t.TraceId == ([16]byte{}) || t.ParentId == ([8]byte{}) {
+		return t, false
+	}
+	t.Flags = flags[0]
+	return t, true
+}
+
+// The trace of the request: a child span of the incoming traceparent, a new
+// trace or (if GenerateTraces is false) a zero Trace.
+func LoadTrace(conn *fasthttp.RequestCtx) Trace {
+	t, ok := ParseTraceparent(conn.Request.Header.PeekBytes(traceparentHeader))
+	if !ok {
+		if !GenerateTraces {
+			return Trace{}
+		}
+		t = Trace{Flags: 0x01}
+		rand.Read(t.TraceId[:])
+	}
+	rand.Read(t.SpanId[:])
+	return t
+}
+
+// The trace loaded by Handler (or NoEnvHandler) for this request.
+func GetTrace(conn *fasthttp.RequestCtx) Trace {
+	t, _ := conn.UserValue(traceUserValue).(Trace)
+	return t
+}
+
+func (t Trace) IsZero() bool {
+	return t.TraceId == [16]byte{}
+}
+
+func (t Trace) TraceIdString() string {
+	return hex.EncodeToString(t.TraceId[:])
+}
+
+func (t Trace) SpanIdString() string {
+	return hex.EncodeToString(t.SpanId[:])
+}
+
+// The traceparent header value to send to downstream services (or back to
+// the client), identifying this request's span.
+func (t Trace) Traceparent() string {
+	var buf [55]byte
+	buf[0], buf[1], buf[2] = '0', '0', '-'
+	hex.Encode(buf[3:35], t.TraceId[:])
+	buf[35] = '-'
+	hex.Encode(buf[36:52], t.SpanId[:])
+	buf[52] = '-'
+	hex.Encode(buf[53:55], []byte{t.Flags})
+	return string(buf[:])
+}
+
+// Adds the trace_id, span_id and (if present) parent_id to the logger. Meant
+// to be called on an env's logger before it's made MultiUse. A zero Trace
+// adds nothing.
+func (t Trace) Log(logger log.Logger) log.Logger {
+	if t.IsZero() {
+		return logger
+	}
+
+	// the logger copies the value, so our scratch space can be re-used
+	var scratch [32]byte
+	logger.String("trace_id", hexEncode(scratch[:], t.TraceId[:]))
+	logger.String("span_id", hexEncode(scratch[:], t.SpanId[:]))
+	if t.ParentId != ([8]byte{}) {
+		logger.String("parent_id", hexEncode(scratch[:], t.ParentId[:]))
+	}
+	return logger
+}
+
+func hexEncode(dst []byte, src []byte) string {
+	n := hex.Encode(dst, src)
+	return utils.B2S(dst[:n])
+}
+
+// Only lowercase hex is valid in a traceparent
+func decodeHex(dst []byte, src []byte) bool {
+	for _, c := range src {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	_, err := hex.Decode(dst, src)
+	return err == nil
+}
+
+// Loads the trace, makes it available via GetTrace and sets the
+// traceparent response header
+func startTrace(conn *fasthttp.RequestCtx) Trace {
+	t := LoadTrace(conn)
+	if !t.IsZero() {
+		conn.SetUserValue(traceUserValue, t)
+		conn.Response.Header.SetBytesK(traceparentHeader, t.Traceparent())
+	}
+	return t
+}
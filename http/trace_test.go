@@ -0,0 +1,162 @@
+package http
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func Test_ParseTraceparent_Valid(t *testing.T) {
+	trace, ok := ParseTraceparent([]byte(testTraceparent))
+	assert.True(t, ok)
+	assert.Equal(t, trace.TraceIdString(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, trace.ParentId, [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7})
+	assert.Equal(t, trace.Flags, 1)
+
+	// future versions can have additional fields
+	_, ok = ParseTraceparent([]byte("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"))
+	assert.True(t, ok)
+}
+
+func Test_ParseTraceparent_Invalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0z",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x",
+	} {
+		_, ok := ParseTraceparent([]byte(value))
+		assert.False(t, ok)
+	}
+}
+
+func Test_LoadTrace_Continues(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("traceparent", testTraceparent)
+
+	trace := LoadTrace(conn)
+	assert.Equal(t, trace.TraceIdString(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, trace.Flags, 1)
+	assert.False(t, trace.SpanId == [8]byte{})
+	assert.False(t, trace.SpanId == trace.ParentId)
+}
+
+func Test_LoadTrace_Generates(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("traceparent", "invalid")
+
+	// off by default
+	assert.True(t, LoadTrace(conn).IsZero())
+
+	GenerateTraces = true
+	defer func() { GenerateTraces = false }()
+
+	trace := LoadTrace(conn)
+	assert.False(t, trace.IsZero())
+	assert.True(t, trace.ParentId == [8]byte{})
+	assert.Equal(t, len(trace.SpanIdString()), 16)
+}
+
+func Test_Trace_Traceparent(t *testing.T) {
+	trace, _ := ParseTraceparent([]byte(testTraceparent))
+	trace.SpanId = [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+	assert.Equal(t, trace.Traceparent(), "00-4bf92f3577b34da6a3ce929d0e0e4736-0102030405060708-01")
+}
+
+func Test_Trace_Log(t *testing.T) {
+	trace, _ := ParseTraceparent([]byte(testTraceparent))
+	trace.SpanId = [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	logger := log.NewKvLogger(1024, nil, log.INFO, true)
+	fields := log.KvParse(string(trace.Log(logger).Bytes()))
+	assert.Equal(t, fields["trace_id"], "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, fields["span_id"], "0102030405060708")
+	assert.Equal(t, fields["parent_id"], "00f067aa0ba902b7")
+
+	logger = log.NewKvLogger(1024, nil, log.INFO, true)
+	assert.Equal(t, len(Trace{}.Log(logger).Bytes()), 0)
+}
+
+func Test_Handler_Trace(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("traceparent", testTraceparent)
+
+	var trace Trace
+	logged := tests.CaptureLog(func() {
+		NoEnvHandler("test-route", func(conn *fasthttp.RequestCtx) (Response, error) {
+			trace = GetTrace(conn)
+			return OK(nil), nil
+		})(conn)
+	})
+
+	assert.Equal(t, trace.TraceIdString(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, string(conn.Response.Header.Peek("traceparent")), trace.Traceparent())
+
+	reqLog := log.KvParse(logged)
+	assert.Equal(t, reqLog["trace_id"], "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, reqLog["span_id"], trace.SpanIdString())
+	assert.Equal(t, reqLog["parent_id"], "00f067aa0ba902b7")
+}
+
+func Test_Handler_Trace_Env(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("traceparent", testTraceparent)
+
+	logged := tests.CaptureLog(func() {
+		Handler("test-route", func(conn *fasthttp.RequestCtx) (*TestEnv, Response, error) {
+			return testEnv(1), nil, nil
+		}, func(conn *fasthttp.RequestCtx, env *TestEnv) (Response, error) {
+			return OK(nil), nil
+		})(conn)
+	})
+
+	reqLog := log.KvParse(logged)
+	assert.Equal(t, reqLog["_l"], "req")
+	assert.Equal(t, reqLog["trace_id"], "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, reqLog["span_id"], GetTrace(conn).SpanIdString())
+	assert.Equal(t, reqLog["parent_id"], "00f067aa0ba902b7")
+}
+
+func Test_Handler_Trace_TracedEnv(t *testing.T) {
+	conn := &fasthttp.RequestCtx{}
+	conn.Request.Header.Set("traceparent", testTraceparent)
+
+	env := &TracedTestEnv{TestEnv: testEnv(1)}
+	logged := tests.CaptureLog(func() {
+		Handler("test-route", func(conn *fasthttp.RequestCtx) (*TracedTestEnv, Response, error) {
+			return env, nil, nil
+		}, func(conn *fasthttp.RequestCtx, env *TracedTestEnv) (Response, error) {
+			return OK(nil), nil
+		})(conn)
+	})
+
+	assert.Equal(t, env.trace, GetTrace(conn))
+
+	// stamped once, by the env
+	assert.Equal(t, strings.Count(logged, "trace_id="), 1)
+	reqLog := log.KvParse(logged)
+	assert.Equal(t, reqLog["_l"], "req")
+	assert.Equal(t, reqLog["trace_id"], "4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
+type TracedTestEnv struct {
+	*TestEnv
+	trace Trace
+}
+
+func (e *TracedTestEnv) SetTrace(t Trace) {
+	e.trace = t
+	t.Log(e.logger).MultiUse()
+}
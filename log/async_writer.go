@@ -0,0 +1,223 @@
+package log
+
+/*
+An io.Writer that moves the actual writing of log entries off of the
+caller's goroutine. Meant to wrap Out (e.g. os.Stderr) so that a slow
+destination doesn't stall request handlers.
+
+Loggers re-use their buffer as soon as Write returns, so each entry is copied
+into one of a fixed number of pre-allocated buffers. Filled buffers are queued
+and drained by a single background goroutine which copies as many entries as
+are available (up to batchSize bytes) into a batch and writes that batch to
+the underlying writer in a single call. Drained buffers are immediately made
+available again.
+
+When every buffer is in use (the underlying writer can't keep up), what
+happens depends on the configured Overflow:
+  - OverflowBlock:      Write blocks until a buffer is available
+  - OverflowDropNewest: the entry being written is dropped
+  - OverflowDropOldest: the oldest queued entry is dropped to make room
+
+Dropped entries (including entries larger than the buffer's maximum size)
+are counted and available via Dropped().
+*/
+
+import (
+	"io"
+	"sync/atomic"
+
+	"src.goblgobl.com/utils/buffer"
+)
+
+type Overflow uint8
+
+const (
+	OverflowBlock Overflow = iota
+	OverflowDropNewest
+	OverflowDropOldest
+)
+
+type AsyncWriter struct {
+	// the writer that we're ultimately writing to
+	out io.Writer
+
+	// buffers available to be written into
+	free chan *buffer.Buffer
+
+	// buffers waiting to be written to out
+	queue chan *buffer.Buffer
+
+	// entries are copied into this and written to out in a single call
+	batch *buffer.Buffer
+
+	// Flush sends a channel which is closed once the queue is drained
+	flush chan chan struct{}
+
+	// closed to tell our background goroutine to drain and stop
+	stop chan struct{}
+
+	// closed by our background goroutine once it has stopped
+	done chan struct{}
+
+	overflow Overflow
+	closed   atomic.Bool
+	dropped  atomic.Uint64
+}
+
+func NewAsyncWriter(out io.Writer, count uint16, maxSize uint32, batchSize uint32, overflow Overflow) *AsyncWriter {
+	if count == 0 {
+		count = 1
+	}
+
+	minSize := uint32(1024)
+	if maxSize < minSize {
+		minSize = maxSize
+	}
+
+	free := make(chan *buffer.Buffer, count)
+	for i := uint16(0); i < count; i++ {
+		free <- buffer.New(minSize, maxSize)
+	}
+
+	w := &AsyncWriter{
+		out:      out,
+		free:     free,
+		overflow: overflow,
+		queue:    make(chan *buffer.Buffer, count),
+		batch:    buffer.New(batchSize, batchSize),
+		flush:    make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go w.run()
+	return w
+}
+
+// io.Writer. Always returns len(data) and a nil error, even when the entry is
+// dropped (there's nothing a logger could do about the error). Once closed,
+// entries are written directly to the underlying writer.
+func (w *AsyncWriter) Write(data []byte) (int, error) {
+	if w.closed.Load() {
+		return w.out.Write(data)
+	}
+
+	var b *buffer.Buffer
+	select {
+	case b = <-w.free:
+	default:
+		switch w.overflow {
+		case OverflowDropNewest:
+			w.dropped.Add(1)
+			return len(data), nil
+		case OverflowDropOldest:
+			select {
+			case b = <-w.queue:
+				w.dropped.Add(1)
+				b.Reset()
+			case b = <-w.free:
+			}
+		default:
+			b = <-w.free
+		}
+	}
+
+	if _, err := b.Write(data); err != nil {
+		w.dropped.Add(1)
+		b.Reset()
+		w.free <- b
+		return len(data), nil
+	}
+
+	// can't block, there are as many slots in the queue as there are buffers
+	w.queue <- b
+	return len(data), nil
+}
+
+// The number of entries that were dropped, either because of our overflow
+// policy or because they were too large.
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Blocks until every entry written before the call to Flush has been
+// written to the underlying writer.
+func (w *AsyncWriter) Flush() {
+	if w.closed.Load() {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case w.flush <- done:
+		<-done
+	case <-w.done:
+	}
+}
+
+// io.Closer. Drains any queued entries and stops the background goroutine.
+// Should only be called once the application is done logging (entries being
+// written concurrently with the call to Close might be lost).
+func (w *AsyncWriter) Close() error {
+	if w.closed.Swap(true) {
+		return nil
+	}
+	close(w.stop)
+	<-w.done
+	return nil
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	for {
+		select {
+		case b := <-w.queue:
+			w.add(b)
+			w.drain()
+		case done := <-w.flush:
+			w.drain()
+			close(done)
+		case <-w.stop:
+			w.drain()
+			return
+		}
+	}
+}
+
+// Adds every queued entry to our batch and writes the batch
+func (w *AsyncWriter) drain() {
+	for {
+		select {
+		case b := <-w.queue:
+			w.add(b)
+		default:
+			w.write()
+			return
+		}
+	}
+}
+
+func (w *AsyncWriter) add(b *buffer.Buffer) {
+	batch := w.batch
+	entry := b.OKBytes()
+
+	if batch.Len()+len(entry) > batch.Max() {
+		w.write()
+	}
+
+	if len(entry) > batch.Max() {
+		w.out.Write(entry)
+	} else {
+		batch.Write(entry)
+	}
+
+	b.Reset()
+	w.free <- b
+}
+
+func (w *AsyncWriter) write() {
+	batch := w.batch
+	if batch.Len() > 0 {
+		w.out.Write(batch.OKBytes())
+		batch.Reset()
+	}
+}
@@ -0,0 +1,187 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_AsyncWriter_WritesAndFlushes(t *testing.T) {
+	out := &gatedWriter{}
+	w := NewAsyncWriter(out, 4, 64, 128, OverflowBlock)
+	defer w.Close()
+
+	for _, entry := range []string{"a\n", "b\n", "c\n", "d\n", "e\n", "f\n"} {
+		w.Write([]byte(entry))
+	}
+	w.Flush()
+	assert.Equal(t, out.String(), "a\nb\nc\nd\ne\nf\n")
+	assert.Equal(t, w.Dropped(), 0)
+}
+
+func Test_AsyncWriter_CopiesEntry(t *testing.T) {
+	out := &gatedWriter{}
+	w := NewAsyncWriter(out, 4, 64, 128, OverflowBlock)
+	defer w.Close()
+
+	entry := []byte("hello\n")
+	w.Write(entry)
+	// loggers re-use their buffer as soon as Write returns
+	copy(entry, "xxxxx\n")
+	w.Flush()
+	assert.Equal(t, out.String(), "hello\n")
+}
+
+func Test_AsyncWriter_TooLarge(t *testing.T) {
+	out := &gatedWriter{}
+	w := NewAsyncWriter(out, 4, 8, 128, OverflowBlock)
+	defer w.Close()
+
+	w.Write([]byte("123456789\n"))
+	w.Write([]byte("1234567\n"))
+	w.Flush()
+	assert.Equal(t, out.String(), "1234567\n")
+	assert.Equal(t, w.Dropped(), 1)
+}
+
+func Test_AsyncWriter_LargerThanBatch(t *testing.T) {
+	out := &gatedWriter{}
+	w := NewAsyncWriter(out, 4, 64, 8, OverflowBlock)
+	defer w.Close()
+
+	w.Write([]byte("a\n"))
+	w.Write([]byte("123456789\n"))
+	w.Write([]byte("b\n"))
+	w.Flush()
+	assert.Equal(t, out.String(), "a\n123456789\nb\n")
+}
+
+func Test_AsyncWriter_DropNewest(t *testing.T) {
+	out := newGatedWriter()
+	w := NewAsyncWriter(out, 2, 64, 128, OverflowDropNewest)
+
+	w.Write([]byte("a\n"))
+	<-out.entered // our background writer is now stuck writing "a"
+
+	w.Write([]byte("b\n"))
+	w.Write([]byte("c\n"))
+	w.Write([]byte("d\n"))
+	assert.Equal(t, w.Dropped(), 1)
+
+	close(out.gate)
+	w.Close()
+	assert.Equal(t, out.String(), "a\nb\nc\n")
+}
+
+func Test_AsyncWriter_DropOldest(t *testing.T) {
+	out := newGatedWriter()
+	w := NewAsyncWriter(out, 2, 64, 128, OverflowDropOldest)
+
+	w.Write([]byte("a\n"))
+	<-out.entered // our background writer is now stuck writing "a"
+
+	w.Write([]byte("b\n"))
+	w.Write([]byte("c\n"))
+	w.Write([]byte("d\n"))
+	assert.Equal(t, w.Dropped(), 1)
+
+	close(out.gate)
+	w.Close()
+	assert.Equal(t, out.String(), "a\nc\nd\n")
+}
+
+func Test_AsyncWriter_Block(t *testing.T) {
+	out := newGatedWriter()
+	w := NewAsyncWriter(out, 1, 64, 128, OverflowBlock)
+
+	w.Write([]byte("a\n"))
+	<-out.entered // our background writer is now stuck writing "a"
+	w.Write([]byte("b\n"))
+
+	written := make(chan struct{})
+	go func() {
+		w.Write([]byte("c\n"))
+		close(written)
+	}()
+
+	close(out.gate)
+	<-written
+	w.Close()
+	assert.Equal(t, out.String(), "a\nb\nc\n")
+	assert.Equal(t, w.Dropped(), 0)
+}
+
+func Test_AsyncWriter_Closed(t *testing.T) {
+	out := &gatedWriter{}
+	w := NewAsyncWriter(out, 4, 64, 128, OverflowBlock)
+	w.Write([]byte("a\n"))
+	assert.Nil(t, w.Close())
+	assert.Equal(t, out.String(), "a\n")
+
+	// written directly
+	w.Write([]byte("b\n"))
+	assert.Equal(t, out.String(), "a\nb\n")
+
+	// safe to call multiple times
+	w.Flush()
+	assert.Nil(t, w.Close())
+}
+
+func Test_Configure_Async(t *testing.T) {
+	original := Out
+	defer func() { Out = original }()
+
+	out := &gatedWriter{}
+	Out = out
+
+	err := Configure(Config{Async: &AsyncConfig{Overflow: "fail"}})
+	assert.Equal(t, err.Error(), "code: 3006 - log.async.overflow is invalid. Should be one of: block, drop_newest or drop_oldest")
+
+	err = Configure(Config{Level: "info", Async: &AsyncConfig{Overflow: "drop_oldest"}})
+	assert.Nil(t, err)
+	assert.True(t, Out == globalAsync)
+	assert.Equal(t, globalAsync.overflow, OverflowDropOldest)
+
+	Info("async").String("a", "b").Log()
+	Flush()
+	assert.StringContains(t, out.String(), "_c=async a=b\n")
+
+	// reconfiguring without async unwraps our writer
+	err = Configure(Config{})
+	assert.Nil(t, err)
+	assert.True(t, globalAsync == nil)
+	assert.True(t, Out == out)
+	assert.Nil(t, Close())
+}
+
+type gatedWriter struct {
+	sync.Mutex
+	out     strings.Builder
+	gate    chan struct{}
+	entered chan struct{}
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{
+		gate:    make(chan struct{}),
+		entered: make(chan struct{}, 10),
+	}
+}
+
+func (w *gatedWriter) Write(data []byte) (int, error) {
+	if w.gate != nil {
+		w.entered <- struct{}{}
+		<-w.gate
+	}
+	w.Lock()
+	defer w.Unlock()
+	return w.out.Write(data)
+}
+
+func (w *gatedWriter) String() string {
+	w.Lock()
+	defer w.Unlock()
+	return w.out.String()
+}
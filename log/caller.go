@@ -0,0 +1,131 @@
+package log
+
+/*
+Optional capture of where an entry was logged from. When enabled (see
+CallerConfig), entries at or above the configured level include a _src
+field (the file:line that started the entry), and FATAL entries also include
+a compact _stack.
+
+Frames from within this package are skipped, so _src is the application's
+call to Info/Warn/Error/Fatal regardless of whether that went through the
+global pool or a checked-out logger.
+
+When disabled, the only cost is a level comparison.
+*/
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maximum number of frames written to _stack
+	maxStackFrames = 16
+
+	packagePrefix = "src.goblgobl.com/utils/log."
+)
+
+// Entries at or above this level include the caller. NONE disables caller
+// and stack capture. Set by Configure.
+var callerLevel = NONE
+
+// Request entries pass NONE, so they never include the caller (it would
+// always be the http handler).
+func captureCaller(level Level) bool {
+	return level >= callerLevel && level != NONE
+}
+
+// Returns a Field with a compact stack trace (_stack) of the caller, or an
+// empty Field if caller capture isn't enabled. skip is the number of
+// additional frames (above the caller of StackField) to leave out.
+func StackField(skip int) Field {
+	if callerLevel == NONE {
+		return Field{}
+	}
+	return ForceStackField(skip)
+}
+
+// Like StackField, but captures the stack even if caller capture isn't
+// enabled. Meant for rare events, such as a recovered panic.
+func ForceStackField(skip int) Field {
+	var scratch [1024]byte
+	return NewField().String("_stack", string(appendStack(scratch[:0], skip))).Finalize()
+}
+
+// Appends "dir/file.go:line" of the first frame outside of this package
+func appendCaller(dst []byte) []byte {
+	var pcs [16]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if !internalFrame(frame) {
+			dst = append(dst, shortFile(frame.File)...)
+			dst = append(dst, ':')
+			return strconv.AppendInt(dst, int64(frame.Line), 10)
+		}
+		if !more {
+			return dst
+		}
+	}
+}
+
+// Appends a comma-separated list of "pkg.Function:line", starting at the
+// first frame outside of this package (plus skip). runtime frames are left out.
+func appendStack(dst []byte, skip int) []byte {
+	var pcs [64]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+
+	written := 0
+	inside := true
+	for written < maxStackFrames {
+		frame, more := frames.Next()
+		if inside && internalFrame(frame) {
+			if !more {
+				break
+			}
+			continue
+		}
+		inside = false
+
+		if skip > 0 {
+			skip--
+		} else if !strings.HasPrefix(frame.Function, "runtime.") {
+			if written > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, shortFunction(frame.Function)...)
+			dst = append(dst, ':')
+			dst = strconv.AppendInt(dst, int64(frame.Line), 10)
+			written++
+		}
+
+		if !more {
+			break
+		}
+	}
+	return dst
+}
+
+// A frame from this package, excluding its tests
+func internalFrame(frame runtime.Frame) bool {
+	return strings.HasPrefix(frame.Function, packagePrefix) && !strings.HasSuffix(frame.File, "_test.go")
+}
+
+// "/src/app/handlers/users.go" => "handlers/users.go"
+func shortFile(file string) string {
+	if i := strings.LastIndexByte(file, '/'); i > 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			return file[j+1:]
+		}
+	}
+	return file
+}
+
+// "src.goblgobl.com/utils/http.Handler.func1" => "http.Handler.func1"
+func shortFunction(function string) string {
+	if i := strings.LastIndexByte(function, '/'); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
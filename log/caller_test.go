@@ -0,0 +1,109 @@
+package log
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_Caller_Disabled(t *testing.T) {
+	out := &strings.Builder{}
+	l := KvFactory(512)(nil, INFO, true)
+
+	l.Fatal("f").LogTo(out)
+	fields := KvParse(out.String())
+	_, exists := fields["_src"]
+	assert.False(t, exists)
+	_, exists = fields["_stack"]
+	assert.False(t, exists)
+
+	field := StackField(0)
+	assert.Equal(t, len(field.KV()), 0)
+}
+
+func Test_Caller_Level(t *testing.T) {
+	defer setCallerLevel(WARN)()
+
+	out := &strings.Builder{}
+	l := KvFactory(1024)(nil, INFO, true)
+
+	l.Info("i").LogTo(out)
+	_, exists := KvParse(out.String())["_src"]
+	assert.False(t, exists)
+
+	out.Reset()
+	l.Request("r").LogTo(out)
+	_, exists = KvParse(out.String())["_src"]
+	assert.False(t, exists)
+
+	out.Reset()
+	l.Warn("w").LogTo(out)
+	fields := KvParse(out.String())
+	assert.StringContains(t, fields["_src"], "log/caller_test.go:")
+	_, exists = fields["_stack"]
+	assert.False(t, exists)
+}
+
+func Test_Caller_SkipsPackageFrames(t *testing.T) {
+	defer setCallerLevel(INFO)()
+
+	pool := NewPool(1, INFO, true, KvFactory(1024), nil)
+	out := &strings.Builder{}
+	pool.Info("i").LogTo(out)
+	assert.StringContains(t, KvParse(out.String())["_src"], "log/caller_test.go:")
+}
+
+func Test_Caller_Fatal_Stack(t *testing.T) {
+	defer setCallerLevel(ERROR)()
+
+	out := &strings.Builder{}
+	l := KvFactory(2048)(nil, INFO, true)
+	l.Fatal("f").LogTo(out)
+
+	stack := KvParse(out.String())["_stack"]
+	assert.True(t, strings.HasPrefix(stack, "log.Test_Caller_Fatal_Stack:"))
+	assert.StringContains(t, stack, ",testing.tRunner:")
+}
+
+func Test_Caller_Json(t *testing.T) {
+	defer setCallerLevel(ERROR)()
+
+	out := &strings.Builder{}
+	l := JsonFactory(2048)(nil, INFO, true)
+	l.Fatal("f").LogTo(out)
+
+	line := out.String()
+	assert.StringContains(t, line, `"_src":"log/caller_test.go:`)
+	assert.StringContains(t, line, `"_stack":"log.Test_Caller_Json:`)
+}
+
+func Test_Caller_StackField(t *testing.T) {
+	defer setCallerLevel(FATAL)()
+
+	field := StackField(0)
+	assert.True(t, strings.HasPrefix(string(field.KV()), "_stack=log.Test_Caller_StackField:"))
+	assert.True(t, strings.HasPrefix(string(field.JSON()), `"_stack":"log.Test_Caller_StackField:`))
+
+	field = StackField(1)
+	assert.True(t, strings.HasPrefix(string(field.KV()), "_stack=testing.tRunner:"))
+}
+
+func Test_Caller_ForceStackField(t *testing.T) {
+	field := ForceStackField(0)
+	assert.True(t, strings.HasPrefix(string(field.KV()), "_stack=log.Test_Caller_ForceStackField:"))
+}
+
+func Test_Caller_Disabled_NoAllocations(t *testing.T) {
+	l := KvFactory(512)(nil, INFO, true)
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Error("e").String("a", "b").LogTo(io.Discard)
+	})
+	assert.Equal(t, allocs, 0)
+}
+
+func setCallerLevel(level Level) func() {
+	callerLevel = level
+	return func() { callerLevel = NONE }
+}
@@ -7,34 +7,97 @@ import (
 )
 
 type Config struct {
-	Requests *bool    `json:"requests"`
-	Level    string   `json:"level"`
-	Format   string   `json:"format"`
-	PoolSize uint16   `json:"pool_size"`
-	KV       KvConfig `json:"kv"`
+	Requests *bool           `json:"requests"`
+	Level    string          `json:"level"`
+	Format   string          `json:"format"`
+	PoolSize uint16          `json:"pool_size"`
+	KV       KvConfig        `json:"kv"`
+	Json     JsonConfig      `json:"json"`
+	Async    *AsyncConfig    `json:"async"`
+	Sampling *SamplingConfig `json:"sampling"`
+	Caller   *CallerConfig   `json:"caller"`
+
+	// context (or prefix*) => level, see SetLevels
+	Levels map[string]string `json:"levels"`
+
+	// when set, entries are written to each matching sink rather than Out
+	Sinks []SinkConfig `json:"sinks"`
+
+	// re-open file sinks when the process receives a SIGHUP (e.g. from logrotate)
+	ReopenOnSighup bool `json:"reopen_on_sighup"`
+
+	// key (or pattern) => mask or hash, see SetRedactions
+	Redact map[string]string `json:"redact"`
+
+	// key of the HMAC used by hash redactions (defaults to a random
+	// per-process key), see SetRedactionKey
+	RedactKey string `json:"redact_key"`
+
+	// how Time values are written: rfc3339 (default) or unix_ms
+	TimeFormat string `json:"time_format"`
 }
 
 type KvConfig struct {
 	MaxSize uint32 `json:"max_size"`
 }
 
+type JsonConfig struct {
+	MaxSize uint32 `json:"max_size"`
+}
+
+// When set, entries are written to Out by a background goroutine
+// (see AsyncWriter)
+type AsyncConfig struct {
+	Count     uint16 `json:"count"`
+	MaxSize   uint32 `json:"max_size"`
+	BatchSize uint32 `json:"batch_size"`
+	Overflow  string `json:"overflow"`
+}
+
+// When set, INFO, WARN and request entries are sampled per context
+// (see Sampler)
+type SamplingConfig struct {
+	First      uint32 `json:"first"`
+	Thereafter uint32 `json:"thereafter"`
+}
+
+// A destination for entries (see Sink). Path is "stdout", "stderr" or a file
+// (opened for appending). An empty path means Out. Level defaults to INFO and
+// Requests (include, exclude or only) to include.
+type SinkConfig struct {
+	Name     string        `json:"name"`
+	Path     string        `json:"path"`
+	Level    string        `json:"level"`
+	Requests string        `json:"requests"`
+	Rotate   *RotateConfig `json:"rotate"`
+}
+
+// Rotation of a file sink (see RotatingFile). MaxSize is in bytes, Interval
+// and MaxAge are durations (e.g. "24h"). Zero values disable the respective
+// rotation or cleanup.
+type RotateConfig struct {
+	MaxSize    uint64 `json:"max_size"`
+	Interval   string `json:"interval"`
+	MaxAge     string `json:"max_age"`
+	MaxBackups uint16 `json:"max_backups"`
+	Compress   bool   `json:"compress"`
+}
+
+// When set, entries at or above Level include their caller (_src) and
+// FATAL entries (as well as http.ServerError responses) include a compact
+// stack trace (_stack)
+type CallerConfig struct {
+	Level string `json:"level"`
+}
+
 func Configure(config Config) error {
-	var level Level
 	levelName := strings.ToUpper(config.Level)
+	if levelName == "" {
+		levelName = "WARN"
+	}
 
-	switch levelName {
-	case "INFO":
-		level = INFO
-	case "", "WARN":
-		level = WARN
-		levelName = "WARN" // reset this incase it was empty/default
-	case "ERROR":
-		level = ERROR
-	case "FATAL":
-		level = FATAL
-	case "NONE":
-		level = NONE
-	default:
+	level, ok := parseLevel(levelName)
+	if !ok {
 		return Errf(utils.ERR_INVALID_LOG_LEVEL, "log.level is invalid. Should be one of: INFO, WARN, ERROR, FATAL or NONE")
 	}
 
@@ -48,8 +111,117 @@ func Configure(config Config) error {
 		}
 		factory = KvFactory(maxSize)
 		formatName = "KV" // reset this incase it was empty
+	case "JSON":
+		maxSize := config.Json.MaxSize
+		if maxSize == 0 {
+			maxSize = 131072 // 128KB
+		}
+		factory = JsonFactory(maxSize)
 	default:
-		return Errf(utils.ERR_INVALID_LOG_FORMAT, "log.format is invalid. Should be one of: kv")
+		return Errf(utils.ERR_INVALID_LOG_FORMAT, "log.format is invalid. Should be one of: kv, json")
+	}
+
+	timeFormatName := strings.ToUpper(config.TimeFormat)
+	if timeFormatName == "" {
+		timeFormatName = "RFC3339"
+	}
+
+	configuredTimeFormat, ok := parseTimeFormat(timeFormatName)
+	if !ok {
+		return Errf(utils.ERR_INVALID_LOG_TIME_FORMAT, "log.time_format is invalid. Should be one of: rfc3339 or unix_ms")
+	}
+
+	configuredCallerLevel := NONE
+	callerLevelName := "NONE"
+	if callerConfig := config.Caller; callerConfig != nil {
+		callerLevelName = strings.ToUpper(callerConfig.Level)
+		if callerLevelName == "" {
+			callerLevelName = "ERROR"
+		}
+		if configuredCallerLevel, ok = parseLevel(callerLevelName); !ok {
+			return Errf(utils.ERR_INVALID_LOG_LEVEL, "log.caller.level is invalid. Should be one of: INFO, WARN, ERROR, FATAL or NONE")
+		}
+	}
+
+	asyncConfig := config.Async
+	var overflow Overflow
+	if asyncConfig != nil {
+		switch strings.ToUpper(asyncConfig.Overflow) {
+		case "", "BLOCK":
+			overflow = OverflowBlock
+		case "DROP_NEWEST":
+			overflow = OverflowDropNewest
+		case "DROP_OLDEST":
+			overflow = OverflowDropOldest
+		default:
+			return Errf(utils.ERR_INVALID_LOG_OVERFLOW, "log.async.overflow is invalid. Should be one of: block, drop_newest or drop_oldest")
+		}
+	}
+
+	configuredLevels, err := parseLevels(config.Levels)
+	if err != nil {
+		return err
+	}
+	configuredRedactions, err := parseRedactions(config.Redact)
+	if err != nil {
+		return err
+	}
+
+	// the writer that any previously configured async writer is wrapping
+	out := Out
+	if async := globalAsync; async != nil {
+		out = async.out
+	}
+
+	// validated last, since it opens the sinks' files (which it closes on
+	// failure)
+	configuredSinks, err := configureSinks(config.Sinks, out)
+	if err != nil {
+		return err
+	}
+
+	// the config is valid, nothing is applied before this point
+	overrides.Store(configuredLevels)
+	redactions.Store(configuredRedactions)
+	SetRedactionKey([]byte(config.RedactKey))
+	timeFormat = configuredTimeFormat
+	callerLevel = configuredCallerLevel
+
+	// stop any previously configured async writer and restore
+	// the writer that it was wrapping
+	if async := globalAsync; async != nil {
+		async.Close()
+		Out = async.out
+		globalAsync = nil
+	}
+
+	if asyncConfig != nil {
+		count := asyncConfig.Count
+		if count == 0 {
+			count = 1024
+		}
+
+		maxSize := asyncConfig.MaxSize
+		if maxSize == 0 {
+			maxSize = 131072 // 128KB
+		}
+
+		batchSize := asyncConfig.BatchSize
+		if batchSize == 0 {
+			batchSize = 65536 // 64KB
+		}
+
+		globalAsync = NewAsyncWriter(Out, count, maxSize, batchSize, overflow)
+		Out = globalAsync
+
+		for _, sink := range configuredSinks {
+			sink.async = NewAsyncWriter(sink.out, count, maxSize, batchSize, overflow)
+			sink.out = sink.async
+		}
+	}
+	SetSinks(configuredSinks...)
+	if config.ReopenOnSighup {
+		reopenOnSighup()
 	}
 
 	poolSize := config.PoolSize
@@ -62,12 +234,24 @@ func Configure(config Config) error {
 		requests = false
 	}
 
-	globalPool = NewPool(poolSize, level, requests, factory, nil)
+	pool := NewPool(poolSize, level, requests, factory, nil)
+	if sampling := config.Sampling; sampling != nil {
+		pool.SetSampler(NewSampler(sampling.First, sampling.Thereafter))
+	}
+	globalPool = pool
+
 	Info("log_config").
 		String("level", levelName).
 		String("format", formatName).
+		String("time_format", timeFormatName).
 		Int("pool_size", int(poolSize)).
 		Bool("requests", requests).
+		Bool("async", globalAsync != nil).
+		Int("level_overrides", len(config.Levels)).
+		Bool("sampling", config.Sampling != nil).
+		Int("redactions", len(config.Redact)).
+		Int("sinks", len(configuredSinks)).
+		String("caller", callerLevelName).
 		Log()
 	return nil
 }
@@ -13,7 +13,7 @@ func Test_Configure_InvalidLevel(t *testing.T) {
 
 func Test_Configure_InvalidFormat(t *testing.T) {
 	err := Configure(Config{Format: "unknown"})
-	assert.Equal(t, err.Error(), "code: 3002 - log.format is invalid. Should be one of: kv")
+	assert.Equal(t, err.Error(), "code: 3002 - log.format is invalid. Should be one of: kv, json")
 }
 
 func Test_Configure_Defaults(t *testing.T) {
@@ -60,3 +60,88 @@ func Test_Configure_Custom(t *testing.T) {
 		assert.Equal(t, globalPool.level, typed)
 	}
 }
+
+func Test_Configure_Json(t *testing.T) {
+	err := Configure(Config{
+		PoolSize: 16,
+		Format:   "json",
+		Json:     JsonConfig{MaxSize: 200},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, globalPool.Len(), 16)
+
+	l := globalPool.Checkout().(*JsonLogger)
+	defer l.Release()
+	assert.Equal(t, l.buffer.Max(), 200)
+}
+
+func Test_Configure_TimeFormat(t *testing.T) {
+	defer Configure(Config{})
+
+	err := Configure(Config{TimeFormat: "iso"})
+	assert.Equal(t, err.Error(), "code: 3007 - log.time_format is invalid. Should be one of: rfc3339 or unix_ms")
+
+	err = Configure(Config{TimeFormat: "unix_ms"})
+	assert.Nil(t, err)
+	assert.Equal(t, timeFormat, TimeUnixMs)
+
+	err = Configure(Config{})
+	assert.Nil(t, err)
+	assert.Equal(t, timeFormat, TimeRFC3339)
+}
+
+func Test_Configure_Caller(t *testing.T) {
+	defer Configure(Config{})
+
+	err := Configure(Config{Caller: &CallerConfig{Level: "nope"}})
+	assert.Equal(t, err.Error(), "code: 3001 - log.caller.level is invalid. Should be one of: INFO, WARN, ERROR, FATAL or NONE")
+
+	err = Configure(Config{Caller: &CallerConfig{}})
+	assert.Nil(t, err)
+	assert.Equal(t, callerLevel, ERROR)
+
+	err = Configure(Config{})
+	assert.Nil(t, err)
+	assert.Equal(t, callerLevel, NONE)
+}
+
+func Test_Configure_Redact(t *testing.T) {
+	defer Configure(Config{})
+
+	err := Configure(Config{Redact: map[string]string{"password": "nope"}})
+	assert.Equal(t, err.Error(), "code: 3008 - log.redact.password is invalid. Should be one of: mask or hash")
+
+	err = Configure(Config{Redact: map[string]string{"password": "hash"}, RedactKey: "k"})
+	assert.Nil(t, err)
+	_, ok := redaction("password")
+	assert.True(t, ok)
+	assert.Equal(t, string(appendRedacted(nil, RedactHash, []byte("test"))), "[hmac:f90c794a]")
+
+	err = Configure(Config{})
+	assert.Nil(t, err)
+	_, ok = redaction("password")
+	assert.False(t, ok)
+}
+
+func Test_Configure_InvalidIsNotApplied(t *testing.T) {
+	defer Configure(Config{})
+
+	err := Configure(Config{
+		Levels: map[string]string{"x": "info"},
+		Redact: map[string]string{"password": "mask"},
+		Sinks:  []SinkConfig{{Name: "a", Level: "loud"}},
+	})
+	assert.Equal(t, err.Error(), "code: 3009 - log.sinks.a.level is invalid. Should be one of: INFO, WARN, ERROR, FATAL or NONE")
+
+	assert.False(t, enabled(INFO, WARN, "x"))
+	_, ok := redaction("password")
+	assert.False(t, ok)
+
+	err = Configure(Config{
+		Levels: map[string]string{"x": "info"},
+		Redact: map[string]string{"password": "nope"},
+	})
+	assert.NotNil(t, err)
+	assert.False(t, enabled(INFO, WARN, "x"))
+}
@@ -0,0 +1,90 @@
+package log
+
+/*
+Carries a logger's data in a context.Context so that code which doesn't
+have direct access to the request (database helpers, migrations, cache
+loaders) can log with the request's data (its rid, or whatever other
+Fixed or MultiUse data its logger was given).
+
+Loggers aren't thread-safe, are re-used once released and only hold one
+entry at a time (an env's request logger is typically in the middle of the
+request entry while the handler runs). So rather than the logger itself,
+WithContext stores a copy of its data, and each entry started from
+FromContext is its own logger, checked out of the global pool, with that
+data added.
+*/
+
+import (
+	"bytes"
+	"context"
+)
+
+type contextKey struct{}
+
+// Something entries can be started from: either a Logger, a Pool or the
+// value returned by FromContext.
+type Source interface {
+	Info(ctx string) Logger
+	Warn(ctx string) Logger
+	Error(ctx string) Logger
+	Fatal(ctx string) Logger
+}
+
+// Stores the logger's Fixed and MultiUse data (not any in-progress entry)
+// in ctx. The logger itself isn't referenced, so it can be released as
+// usual. The data is only carried over to entries if the logger has the
+// same format as the global pool's loggers.
+func WithContext(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, contextSource{field: persistentField(logger)})
+}
+
+// A Source for entries with the data stored in ctx by WithContext or, if
+// there isn't any (or ctx is nil), the global pool.
+func FromContext(ctx context.Context) Source {
+	if ctx != nil {
+		if source, ok := ctx.Value(contextKey{}).(contextSource); ok {
+			return source
+		}
+	}
+	return globalPool
+}
+
+type contextSource struct {
+	field Field
+}
+
+func (s contextSource) Info(ctx string) Logger {
+	return globalPool.Info(ctx).Field(s.field)
+}
+
+func (s contextSource) Warn(ctx string) Logger {
+	return globalPool.Warn(ctx).Field(s.field)
+}
+
+func (s contextSource) Error(ctx string) Logger {
+	return globalPool.Error(ctx).Field(s.field)
+}
+
+func (s contextSource) Fatal(ctx string) Logger {
+	return globalPool.Fatal(ctx).Field(s.field)
+}
+
+// A copy of the logger's Fixed and MultiUse data, as a Field
+func persistentField(logger Logger) Field {
+	switch l := logger.(type) {
+	case *KvLogger:
+		n := int(l.fixedLen)
+		if m := int(l.multiUseLen); m > n {
+			n = m
+		}
+		return Field{kv: bytes.Clone(l.buffer.OKBytes()[:n])}
+	case *JsonLogger:
+		n := int(l.fixedLen)
+		if m := int(l.multiUseLen); m > n {
+			n = m
+		}
+		// skip the opening '{'
+		return Field{json: bytes.Clone(l.buffer.OKBytes()[1:n])}
+	}
+	return Field{}
+}
@@ -0,0 +1,96 @@
+package log
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_FromContext_Default(t *testing.T) {
+	assert.True(t, FromContext(context.Background()) == Source(globalPool))
+	assert.True(t, FromContext(nil) == Source(globalPool))
+}
+
+func Test_FromContext_Logger(t *testing.T) {
+	defer kvGlobalPool()()
+	out := &strings.Builder{}
+	l := KvFactory(128)(nil, INFO, true)
+	l.Field(NewField().String("rid", "r9").Finalize()).MultiUse()
+
+	ctx := WithContext(context.Background(), l)
+	FromContext(ctx).Info("a").LogTo(out)
+	FromContext(ctx).Error("b").LogTo(out)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	assert.Equal(t, KvParse(lines[0])["rid"], "r9")
+	assert.Equal(t, KvParse(lines[0])["_c"], "a")
+	assert.Equal(t, KvParse(lines[1])["rid"], "r9")
+	assert.Equal(t, KvParse(lines[1])["_c"], "b")
+}
+
+func Test_FromContext_Detached(t *testing.T) {
+	defer kvGlobalPool()()
+	released := 0
+	l := NewKvLogger(128, func(Logger) { released += 1 }, INFO, true)
+	l.Field(NewField().String("rid", "r9").Finalize()).MultiUse()
+	l.Request("route").String("id", "x")
+	ctx := WithContext(context.Background(), l)
+
+	out := &strings.Builder{}
+	FromContext(ctx).Info("a").LogTo(out)
+	fields := KvParse(strings.TrimSpace(out.String()))
+	assert.Equal(t, fields["rid"], "r9")
+	assert.Equal(t, fields["_c"], "a")
+	_, exists := fields["id"]
+	assert.False(t, exists)
+
+	// the logger's in-progress entry is untouched
+	assert.Equal(t, released, 0)
+	assert.Equal(t, KvParse(string(l.Bytes()))["id"], "x")
+
+	// and it can be released (and re-used) while ctx is still around
+	l.Release()
+	l.Field(NewField().String("rid", "r10").Finalize()).MultiUse()
+	out.Reset()
+	FromContext(ctx).Info("b").LogTo(out)
+	assert.Equal(t, KvParse(strings.TrimSpace(out.String()))["rid"], "r9")
+}
+
+func Test_FromContext_NotMultiUse(t *testing.T) {
+	defer kvGlobalPool()()
+	released := 0
+	l := NewKvLogger(128, func(Logger) { released += 1 }, INFO, true)
+	l.Field(NewField().String("pid", "p1").Finalize()).Fixed()
+	l.Info("in_progress")
+	ctx := WithContext(context.Background(), l)
+
+	out := &strings.Builder{}
+	FromContext(ctx).Info("a").LogTo(out)
+	FromContext(ctx).Info("b").LogTo(out)
+	assert.Equal(t, released, 0)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	assert.Equal(t, KvParse(lines[1])["pid"], "p1")
+	assert.Equal(t, KvParse(lines[1])["_c"], "b")
+}
+
+func Test_FromContext_Json(t *testing.T) {
+	l := NewJsonLogger(128, nil, INFO, true)
+	l.Field(NewField().String("rid", "r9").Finalize()).MultiUse()
+	assert.Equal(t, string(persistentField(l).json), `"rid":"r9"`)
+
+	assert.Equal(t, len(persistentField(NewJsonLogger(128, nil, INFO, true)).json), 0)
+	assert.Equal(t, len(persistentField(Noop{}).kv), 0)
+}
+
+// Entries from FromContext come from the global pool, which other tests
+// might have configured differently
+func kvGlobalPool() func() {
+	original := globalPool
+	globalPool = NewPool(4, INFO, true, KvFactory(256), nil)
+	return func() { globalPool = original }
+}
@@ -1,8 +1,10 @@
 package log
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"time"
 )
 
 // An error that's designed to be logged in a more structured manner
@@ -38,6 +40,30 @@ func (e *StructuredError) Binary(key string, value []byte) *StructuredError {
 	return e
 }
 
+func (e *StructuredError) Float(key string, value float64) *StructuredError {
+	e.ensureMap()
+	e.Data[key] = value
+	return e
+}
+
+func (e *StructuredError) Uint64(key string, value uint64) *StructuredError {
+	e.ensureMap()
+	e.Data[key] = value
+	return e
+}
+
+func (e *StructuredError) Time(key string, value time.Time) *StructuredError {
+	e.ensureMap()
+	e.Data[key] = value
+	return e
+}
+
+func (e *StructuredError) Duration(key string, value time.Duration) *StructuredError {
+	e.ensureMap()
+	e.Data[key] = value
+	return e
+}
+
 func (e *StructuredError) ensureMap() {
 	if e.Data == nil {
 		e.Data = make(map[string]any, 1)
@@ -77,3 +103,45 @@ func ErrData(code int, err error, data map[string]any) *StructuredError {
 func Errf(code int, format string, args ...any) *StructuredError {
 	return Err(code, fmt.Errorf(format, args...))
 }
+
+// Adds an error to the logger. If the error is, or wraps, a StructuredError,
+// its code and data are added as separate fields. When the StructuredError is
+// wrapped, _err is the full error text so that the wrapping context isn't lost.
+func logErr(l Logger, err error) Logger {
+	var se *StructuredError
+	if !errors.As(err, &se) {
+		return l.String("_err", err.Error())
+	}
+
+	message := err.Error()
+	if err == error(se) {
+		message = se.Err.Error()
+	}
+
+	l.Int("_code", se.Code).String("_err", message)
+	logData(l, se.Data)
+	return l
+}
+
+// Adds a StructuredError's data to the logger. Values of an unsupported
+// type are ignored.
+func logData(l Logger, data map[string]any) {
+	for key, value := range data {
+		switch v := value.(type) {
+		case string:
+			l.String(key, v)
+		case int:
+			l.Int(key, v)
+		case []byte:
+			l.Binary(key, v)
+		case float64:
+			l.Float(key, v)
+		case uint64:
+			l.Uint64(key, v)
+		case time.Time:
+			l.Time(key, v)
+		case time.Duration:
+			l.Duration(key, v)
+		}
+	}
+}
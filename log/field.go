@@ -3,7 +3,9 @@ package log
 import (
 	"fmt"
 	"strconv"
+	"time"
 
+	"src.goblgobl.com/utils"
 	"src.goblgobl.com/utils/buffer"
 )
 
@@ -20,6 +22,7 @@ some optimizations to take place.
 type Field struct {
 	fields map[string]any
 	kv     []byte
+	json   []byte
 }
 
 func NewField() *Field {
@@ -32,6 +35,10 @@ func (f *Field) KV() []byte {
 	return f.kv
 }
 
+func (f *Field) JSON() []byte {
+	return f.json
+}
+
 func (f *Field) Int(key string, value int) *Field {
 	f.fields[key] = value
 	return f
@@ -42,16 +49,52 @@ func (f *Field) String(key string, value string) *Field {
 	return f
 }
 
+func (f *Field) Float(key string, value float64) *Field {
+	f.fields[key] = value
+	return f
+}
+
+func (f *Field) Uint64(key string, value uint64) *Field {
+	f.fields[key] = value
+	return f
+}
+
+// The time is formatted (RFC3339 or unix ms) when Finalize is called
+func (f *Field) Time(key string, value time.Time) *Field {
+	f.fields[key] = value
+	return f
+}
+
+func (f *Field) Duration(key string, value time.Duration) *Field {
+	f.fields[key] = value
+	return f
+}
+
 // return Field so that it can be used in chaining
 func (f *Field) Finalize() Field {
-	buffer := buffer.New(1024, 4096)
+	kvBuffer := buffer.New(1024, 4096)
+	jsonBuffer := buffer.New(1024, 4096)
 
+	var scratch [40]byte
 	for key, value := range f.fields {
 		switch v := value.(type) {
 		case int:
-			writeKeyValue(key, strconv.FormatInt(int64(v), 10), buffer)
+			finalizeRaw(key, strconv.AppendInt(scratch[:0], int64(v), 10), false, kvBuffer, jsonBuffer)
 		case string:
-			writeKeyValue(key, v, buffer)
+			if redaction, ok := redaction(key); ok {
+				finalizeRaw(key, appendRedacted(scratch[:0], redaction, utils.S2B(v)), true, kvBuffer, jsonBuffer)
+				continue
+			}
+			writeKeyValue(key, v, kvBuffer)
+			writeJsonKeyValue(key, v, jsonBuffer)
+		case float64:
+			finalizeRaw(key, appendFloat(scratch[:0], v), !floatIsNumber(v), kvBuffer, jsonBuffer)
+		case uint64:
+			finalizeRaw(key, appendUint64(scratch[:0], v), false, kvBuffer, jsonBuffer)
+		case time.Time:
+			finalizeRaw(key, appendTime(scratch[:0], v), timeIsString(), kvBuffer, jsonBuffer)
+		case time.Duration:
+			finalizeRaw(key, appendDuration(scratch[:0], v), true, kvBuffer, jsonBuffer)
 		default:
 			panic(fmt.Sprintf("unsupport field value type: %T (%v)", value, value))
 		}
@@ -60,10 +103,23 @@ func (f *Field) Finalize() Field {
 	// We expect fields to be created on startup and be long-lived
 	// we should trim out kv data to the exact size to avoid
 	// wasting space
-	kv := make([]byte, buffer.Len())
-	bytes, _ := buffer.Bytes()
-	copy(kv, bytes)
-	f.kv = kv
+	f.kv = trimmedCopy(kvBuffer)
+	f.json = trimmedCopy(jsonBuffer)
 
 	return *f
 }
+
+// Writes a value which doesn't need to be escaped, optionally quoted in JSON
+func finalizeRaw(key string, value []byte, jsonQuoted bool, kvBuffer *buffer.Buffer, jsonBuffer *buffer.Buffer) {
+	if writeKeyForValueLen(key, len(value), kvBuffer) {
+		kvBuffer.Write(value)
+	}
+	writeJsonRawKeyValue(key, value, jsonQuoted, jsonBuffer)
+}
+
+func trimmedCopy(buffer *buffer.Buffer) []byte {
+	data := make([]byte, buffer.Len())
+	bytes, _ := buffer.Bytes()
+	copy(data, bytes)
+	return data
+}
@@ -4,6 +4,7 @@ import (
 	"math"
 	"strconv"
 	"testing"
+	"time"
 
 	"src.goblgobl.com/tests/assert"
 )
@@ -53,3 +54,29 @@ func Test_Field_Multiple(t *testing.T) {
 	assert.Equal(t, kv["type"], "worm")
 	assert.Equal(t, kv["age"], "3000")
 }
+
+func Test_Field_JSON(t *testing.T) {
+	f := NewField().Int("over", 9000).Finalize()
+	assert.Equal(t, string(f.JSON()), `"over":9000`)
+
+	f = NewField().String("name", "ghanima \"atreides\"").Finalize()
+	assert.Equal(t, string(f.JSON()), `"name":"ghanima \"atreides\""`)
+}
+
+func Test_Field_Values(t *testing.T) {
+	f := NewField().Float("f", 0.5).Finalize()
+	assert.Equal(t, string(f.KV()), "f=0.5")
+	assert.Equal(t, string(f.JSON()), `"f":0.5`)
+
+	f = NewField().Uint64("u", 33).Finalize()
+	assert.Equal(t, string(f.KV()), "u=33")
+	assert.Equal(t, string(f.JSON()), `"u":33`)
+
+	f = NewField().Time("t", time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)).Finalize()
+	assert.Equal(t, string(f.KV()), "t=2023-04-05T06:07:08Z")
+	assert.Equal(t, string(f.JSON()), `"t":"2023-04-05T06:07:08Z"`)
+
+	f = NewField().Duration("d", 2*time.Minute).Finalize()
+	assert.Equal(t, string(f.KV()), "d=2m0s")
+	assert.Equal(t, string(f.JSON()), `"d":"2m0s"`)
+}
@@ -0,0 +1,441 @@
+package log
+
+/*
+Same pooling, Fixed and MultiUse semantics as the KvLogger (see the comment
+at the top of kv_logger.go), but each entry is written as a single line of
+JSON (newline-delimited JSON).
+
+The buffer always starts with a '{'. Fixed and MultiUse data are written
+right after it, so every entry generated from the logger includes them. On
+Log, we write the closing "}\n". Every write reserves space for those two
+closing bytes, so we can always close the object, even when a field was
+dropped because the buffer reached its maximum size.
+*/
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/buffer"
+)
+
+const hex = "0123456789abcdef"
+
+type JsonLogger struct {
+	release func(Logger)
+
+	// buffer that we write our message to
+	buffer *buffer.Buffer
+
+	// The log level that we're logging.
+	level Level
+
+	// Whether or not we're logging request messages
+	requests bool
+
+	// The level of the entry being logged (NONE for request entries), used
+	// to pick which sinks the entry is written to
+	entry Level
+
+	// Length of the data that is always included (including the opening '{')
+	fixedLen int64
+
+	// Length of the data included until the logger is released. 0 when
+	// MultiUse isn't enabled.
+	multiUseLen int64
+}
+
+func NewJsonLogger(maxSize uint32, release func(Logger), level Level, requests bool) *JsonLogger {
+	buffer := buffer.New(4096, maxSize)
+	buffer.WriteByte('{')
+
+	return &JsonLogger{
+		level:    level,
+		release:  release,
+		requests: requests,
+		buffer:   buffer,
+		fixedLen: 1,
+	}
+}
+
+func JsonFactory(maxSize uint32) Factory {
+	return func(release func(Logger), level Level, requests bool) Logger {
+		return NewJsonLogger(maxSize, release, level, requests)
+	}
+}
+
+// Get the bytes from the logger. This is only valid before Log is called (after
+// log is called, you'll get an empty slice). Only really useful for testing.
+// The closing '}' isn't included.
+func (l *JsonLogger) Bytes() []byte {
+	return l.buffer.OKBytes()
+}
+
+// See KvLogger.Fixed
+func (l *JsonLogger) Fixed() {
+	l.fixedLen = int64(l.buffer.Len())
+}
+
+// See KvLogger.MultiUse
+func (l *JsonLogger) MultiUse() Logger {
+	l.multiUseLen = int64(l.buffer.Len())
+	return l
+}
+
+// Add a field ("key": "value") where value is a string
+func (l *JsonLogger) String(key string, value string) Logger {
+	if redaction, ok := redaction(key); ok {
+		return l.redacted(key, redaction, utils.S2B(value))
+	}
+	writeJsonKeyValue(key, value, l.buffer)
+	return l
+}
+
+func (l *JsonLogger) redacted(key string, redaction Redaction, value []byte) Logger {
+	var scratch [32]byte
+	l.writeRaw(key, appendRedacted(scratch[:0], redaction, value), true)
+	return l
+}
+
+// Add a field ("key": "value") where value is base64 (url) encoded
+func (l *JsonLogger) Binary(key string, value []byte) Logger {
+	if redaction, ok := redaction(key); ok {
+		return l.redacted(key, redaction, value)
+	}
+	buffer := l.buffer
+	// +2 for the quotes
+	if !writeJsonKeyForValueLen(key, binaryEncoder.EncodedLen(len(value))+2, buffer) {
+		return l
+	}
+
+	buffer.WriteByteUnsafe('"')
+
+	// encode in chunks (multiples of 3 bytes, so that only the last chunk can be
+	// partial) to avoid allocating an encoder or a scratch buffer
+	var scratch [64]byte
+	for len(value) > 0 {
+		n := len(value)
+		if n > 48 {
+			n = 48
+		}
+		binaryEncoder.Encode(scratch[:], value[:n])
+		buffer.Write(scratch[:binaryEncoder.EncodedLen(n)])
+		value = value[n:]
+	}
+
+	buffer.WriteByteUnsafe('"')
+	return l
+}
+
+// Add a field ("key": value) where value is an int
+func (l *JsonLogger) Int(key string, value int) Logger {
+	return l.Int64(key, int64(value))
+}
+
+// Add a field ("key": value) where value is an int64
+func (l *JsonLogger) Int64(key string, value int64) Logger {
+	var scratch [20]byte
+	s := strconv.AppendInt(scratch[:0], value, 10)
+	if writeJsonKeyForValueLen(key, len(s), l.buffer) {
+		l.buffer.Write(s)
+	}
+	return l
+}
+
+// Add a field ("key": value) where value is a boolean
+func (l *JsonLogger) Bool(key string, value bool) Logger {
+	if value {
+		if writeJsonKeyForValueLen(key, 4, l.buffer) {
+			l.buffer.WriteString("true")
+		}
+	} else if writeJsonKeyForValueLen(key, 5, l.buffer) {
+		l.buffer.WriteString("false")
+	}
+	return l
+}
+
+// Add a field ("key": value) where value is a float. NaN and +/-Inf aren't
+// valid JSON numbers, so they're written as strings.
+func (l *JsonLogger) Float(key string, value float64) Logger {
+	var scratch [32]byte
+	l.writeRaw(key, appendFloat(scratch[:0], value), !floatIsNumber(value))
+	return l
+}
+
+// Add a field ("key": value) where value is an uint64
+func (l *JsonLogger) Uint64(key string, value uint64) Logger {
+	var scratch [20]byte
+	l.writeRaw(key, appendUint64(scratch[:0], value), false)
+	return l
+}
+
+// Add a field where value is a time. Depending on the configured time
+// format, the value is either a string (RFC3339) or a number (unix ms).
+func (l *JsonLogger) Time(key string, value time.Time) Logger {
+	var scratch [40]byte
+	l.writeRaw(key, appendTime(scratch[:0], value), timeIsString())
+	return l
+}
+
+// Add a field ("key": "value") where value is a duration
+func (l *JsonLogger) Duration(key string, value time.Duration) Logger {
+	var scratch [32]byte
+	l.writeRaw(key, appendDuration(scratch[:0], value), true)
+	return l
+}
+
+// Add a field ("key": "value") where value is an error
+func (l *JsonLogger) Err(err error) Logger {
+	return logErr(l, err)
+}
+
+func (l *JsonLogger) Field(field Field) Logger {
+	if len(field.json) == 0 {
+		return l
+	}
+	writeJsonRaw(field.json, l.buffer)
+	return l
+}
+
+// Write the log to our globally configured writer (or sinks)
+func (l *JsonLogger) Log() {
+	buffer := l.buffer
+
+	// space for these was reserved by every write
+	buffer.WriteByteUnsafe('}')
+	buffer.WriteByteUnsafe('\n')
+	if !writeSinks(l.entry, buffer.OKBytes()) {
+		Out.Write(buffer.OKBytes())
+	}
+	l.conditionalRelease()
+}
+
+func (l *JsonLogger) LogTo(out io.Writer) {
+	buffer := l.buffer
+
+	// space for these was reserved by every write
+	buffer.WriteByteUnsafe('}')
+	buffer.WriteByteUnsafe('\n')
+	out.Write(buffer.OKBytes())
+	l.conditionalRelease()
+}
+
+func (l *JsonLogger) Reset() {
+	l.buffer.Rewind(int(l.fixedLen))
+}
+
+func (l *JsonLogger) Release() {
+	l.multiUseLen = 0
+	l.buffer.Reset()
+	l.buffer.Seek(l.fixedLen, io.SeekStart)
+	if release := l.release; release != nil {
+		release(l)
+	}
+}
+
+// Normally, logger is automatically released when Log or LogTo is called
+// unless we've enabled multiUse, in which case we rewind to the multiUse data
+func (l *JsonLogger) conditionalRelease() {
+	if l.multiUseLen == 0 {
+		l.Release()
+	} else {
+		// Rewind (not Seek) so that an entry which overflowed the buffer
+		// doesn't leave the error set for every subsequent entry
+		l.buffer.Rewind(int(l.multiUseLen))
+	}
+}
+
+// Log an info-level message.
+func (l *JsonLogger) Info(ctx string) Logger {
+	if !enabled(INFO, l.level, ctx) {
+		l.conditionalRelease()
+		return Noop{}
+	}
+	return l.start(ctx, INFO, []byte(`"_l":"info","_t":`))
+}
+
+// Log an warn-level message.
+func (l *JsonLogger) Warn(ctx string) Logger {
+	if !enabled(WARN, l.level, ctx) {
+		l.conditionalRelease()
+		return Noop{}
+	}
+	return l.start(ctx, WARN, []byte(`"_l":"warn","_t":`))
+}
+
+// Log an error-level message.
+func (l *JsonLogger) Error(ctx string) Logger {
+	if !enabled(ERROR, l.level, ctx) {
+		l.conditionalRelease()
+		return Noop{}
+	}
+	return l.start(ctx, ERROR, []byte(`"_l":"error","_t":`))
+}
+
+// Log an fatal-level message.
+func (l *JsonLogger) Fatal(ctx string) Logger {
+	if !enabled(FATAL, l.level, ctx) {
+		l.conditionalRelease()
+		return Noop{}
+	}
+	return l.start(ctx, FATAL, []byte(`"_l":"fatal","_t":`))
+}
+
+// Log a request message.
+func (l *JsonLogger) Request(route string) Logger {
+	if !l.requests {
+		l.conditionalRelease()
+		return Noop{}
+	}
+	return l.start(route, NONE, []byte(`"_l":"req","_t":`))
+}
+
+// "starts" a new log message. Every message always contains a timestamp (_t) a
+// context (_c) and a level (_l).
+func (l *JsonLogger) start(ctx string, level Level, meta []byte) Logger {
+	l.entry = level
+	var scratch [20]byte
+	t := strconv.AppendInt(scratch[:0], time.Now().Unix(), 10)
+
+	writeJsonRaw(meta, l.buffer)
+	l.buffer.Write(t)
+	writeJsonKeyValue("_c", ctx, l.buffer)
+
+	if captureCaller(level) {
+		l.source(level)
+	}
+	return l
+}
+
+// Writes the caller (_src) and, for FATAL entries, the stack (_stack)
+func (l *JsonLogger) source(level Level) {
+	var scratch [1024]byte
+	writeJsonKeyValue("_src", utils.B2S(appendCaller(scratch[:0])), l.buffer)
+	if level == FATAL {
+		writeJsonKeyValue("_stack", utils.B2S(appendStack(scratch[:0], 0)), l.buffer)
+	}
+}
+
+// Writes a value which we know doesn't need to be escaped, optionally
+// within quotes
+func (l *JsonLogger) writeRaw(key string, value []byte, quoted bool) {
+	writeJsonRawKeyValue(key, value, quoted, l.buffer)
+}
+
+func writeJsonRawKeyValue(key string, value []byte, quoted bool, buffer *buffer.Buffer) {
+	if !quoted {
+		if writeJsonKeyForValueLen(key, len(value), buffer) {
+			buffer.Write(value)
+		}
+		return
+	}
+
+	if writeJsonKeyForValueLen(key, len(value)+2, buffer) {
+		buffer.WriteByteUnsafe('"')
+		buffer.Write(value)
+		buffer.WriteByteUnsafe('"')
+	}
+}
+
+// Writes pre-rendered JSON data (a Field or our start meta), prefixed with a
+// comma if needed.
+func writeJsonRaw(data []byte, buffer *buffer.Buffer) {
+	if len(data) == 0 {
+		return
+	}
+
+	// + 1 for the comma, +2 for the closing }\n
+	// (+20 for the timestamp which is written right after the start meta)
+	if !buffer.EnsureCapacity(len(data) + 23) {
+		return
+	}
+
+	if buffer.Len() > 1 {
+		buffer.WriteByteUnsafe(',')
+	}
+	buffer.Write(data)
+}
+
+// Keys are escaped like values, since some (e.g. a StructuredError's data
+// keys) don't come from us.
+func writeJsonKeyForValueLen(key string, valueLen int, buffer *buffer.Buffer) bool {
+	// +1 for the comma, +3 for the quoted key and the colon, +2 for the closing }\n
+	if !buffer.EnsureCapacity(jsonEscapedLen(key) + valueLen + 6) {
+		return false
+	}
+
+	if buffer.Len() > 1 {
+		buffer.WriteByteUnsafe(',')
+	}
+
+	writeJsonStringUnsafe(key, buffer)
+	buffer.WriteByteUnsafe(':')
+	return true
+}
+
+func writeJsonKeyValue(key string, value string, buffer *buffer.Buffer) {
+	// +2 for the quotes
+	if writeJsonKeyForValueLen(key, jsonEscapedLen(value)+2, buffer) {
+		writeJsonStringUnsafe(value, buffer)
+	}
+}
+
+// Writes value as a quoted and escaped JSON string
+func writeJsonString(value string, buffer *buffer.Buffer) {
+	// +2 for the quotes
+	if buffer.EnsureCapacity(jsonEscapedLen(value) + 2) {
+		writeJsonStringUnsafe(value, buffer)
+	}
+}
+
+// Our caller must have ensured that there's enough space for the
+// escaped value and its quotes
+func writeJsonStringUnsafe(value string, buffer *buffer.Buffer) {
+	buffer.WriteByteUnsafe('"')
+	for _, c := range utils.S2B(value) {
+		switch c {
+		case '"', '\\':
+			buffer.WriteByteUnsafe('\\')
+			buffer.WriteByteUnsafe(c)
+		case '\n':
+			buffer.WriteByteUnsafe('\\')
+			buffer.WriteByteUnsafe('n')
+		case '\r':
+			buffer.WriteByteUnsafe('\\')
+			buffer.WriteByteUnsafe('r')
+		case '\t':
+			buffer.WriteByteUnsafe('\\')
+			buffer.WriteByteUnsafe('t')
+		default:
+			if c < 0x20 {
+				buffer.WriteByteUnsafe('\\')
+				buffer.WriteByteUnsafe('u')
+				buffer.WriteByteUnsafe('0')
+				buffer.WriteByteUnsafe('0')
+				buffer.WriteByteUnsafe(hex[c>>4])
+				buffer.WriteByteUnsafe(hex[c&0xF])
+			} else {
+				buffer.WriteByteUnsafe(c)
+			}
+		}
+	}
+	buffer.WriteByteUnsafe('"')
+}
+
+// The length of value once escaped (excluding the surrounding quotes)
+func jsonEscapedLen(value string) int {
+	l := len(value)
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"', '\\', '\n', '\r', '\t':
+			l += 1
+		default:
+			if c < 0x20 {
+				l += 5
+			}
+		}
+	}
+	return l
+}
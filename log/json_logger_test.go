@@ -0,0 +1,298 @@
+package log
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/typed"
+)
+
+func Test_JsonLogger_Int(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(128)(nil, INFO, true)
+
+	l.Info("i").Int("ms", 0).LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"ms": 0})
+
+	l.Info("i").Int("count", 32).String("x", "b").LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"count": 32, "x": "b"})
+
+	l.Warn("i").Int64("ms", -99).LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"ms": -99})
+}
+
+func Test_JsonLogger_String(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(256)(nil, INFO, true)
+
+	l.Info("i").String("a", `quote " slash \ nl`+"\n\t\x01").LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"a": `quote " slash \ nl` + "\n\t\x01"})
+
+	l.Info(`c"tx`).String("a", "").LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"a": "", "_c": `c"tx`})
+}
+
+func Test_JsonLogger_Binary(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(512)(nil, INFO, true)
+
+	l.Info("i").Binary("b", []byte{1, 2, 3}).LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"b": "AQID"})
+
+	value := make([]byte, 100)
+	for i := range value {
+		value[i] = byte(i)
+	}
+	l.Info("i").Binary("b", value).LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"b": binaryEncoder.EncodeToString(value)})
+}
+
+func Test_JsonLogger_Bool(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(128)(nil, INFO, true)
+
+	l.Info("i").Bool("active", true).LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"active": true})
+
+	l.Info("i").Bool("active", false).String("x", "b").LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"active": false, "x": "b"})
+}
+
+func Test_JsonLogger_Error(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(128)(nil, INFO, true)
+	l.Warn("w").Err(errors.New("test_error")).LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"_err": "test_error"})
+}
+
+func Test_JsonLogger_StructuredError(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(256)(nil, INFO, true)
+	s1 := Err(311, errors.New("test_error2")).String("id", "a").Int("x", 9)
+	s2 := Err(312, s1).String("other", "b").Int("x", 8)
+
+	l.Warn("w").Err(s2).LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{
+		"id":     "a",
+		"other":  "b",
+		"x":      8,
+		"_code":  312,
+		"_icode": 311,
+		"_err":   "code: 311 - test_error2",
+	})
+}
+
+func Test_JsonLogger_Levels(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(128)(nil, WARN, false)
+
+	l.Info("i").String("a", "1").LogTo(out)
+	assert.Equal(t, out.String(), "")
+
+	l.Request("r").String("a", "1").LogTo(out)
+	assert.Equal(t, out.String(), "")
+
+	l.Warn("w").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{"_l": "warn", "_c": "w"})
+
+	l.Error("e").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{"_l": "error", "_c": "e"})
+
+	l.Fatal("f").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{"_l": "fatal", "_c": "f"})
+
+	l = JsonFactory(128)(nil, INFO, true)
+	l.Request("r").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{"_l": "req", "_c": "r"})
+}
+
+func Test_JsonLogger_Timestamp(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(128)(nil, INFO, true)
+
+	l.Info("hi").LogTo(out)
+	fields := assertJsonLog(t, out, false, nil)
+	assert.Nowish(t, time.Unix(int64(fields.Int("_t")), 0))
+}
+
+func Test_JsonLogger_MaxSize(t *testing.T) {
+	out := &strings.Builder{}
+	// info messages take 36 characters + context length, plus 2 for the closing }\n
+	l := JsonFactory(53)(nil, INFO, true)
+
+	l.Info("ctx1").String("a", "1234").LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{"a": "1234"})
+
+	l.Info("ctx1").String("a", "12345").LogTo(out)
+	fields := assertJsonLog(t, out, false, map[string]any{"_c": "ctx1"})
+	assert.False(t, fields.Exists("a"))
+
+	// an oversized field doesn't leak into the next entry
+	l.Info("ctx1").String("b", "\n").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{"_l": "info", "_c": "ctx1", "b": "\n"})
+}
+
+func Test_JsonLogger_MultiUseOverflow(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(80)(nil, INFO, true)
+	l.Field(NewField().String("rid", "r1").Finalize()).MultiUse()
+
+	l.Info("ctx1").String("a", strings.Repeat("x", 100)).LogTo(out)
+	fields := assertJsonLog(t, out, false, map[string]any{"rid": "r1"})
+	assert.False(t, fields.Exists("a"))
+
+	// the overflow doesn't stick to the logger
+	l.Info("ctx2").String("b", "1").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{"_l": "info", "_c": "ctx2", "rid": "r1", "b": "1"})
+}
+
+func Test_JsonLogger_EscapedKeys(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(256)(nil, INFO, true)
+
+	err := ErrData(9, errors.New("fail"), map[string]any{`a"b\c`: 1})
+	l.Error("ctx1").String("d\n", "x").Err(err).LogTo(out)
+	assertJsonLog(t, out, false, map[string]any{`a"b\c`: 1, "d\n": "x"})
+}
+
+func Test_JsonLogger_Fixed(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(128)(nil, INFO, true)
+
+	l.Field(NewField().Int("power", 9001).Finalize()).Fixed()
+	l.LogTo(out)
+	assert.Equal(t, out.String(), "{\"power\":9001}\n")
+
+	out.Reset()
+	l.Reset()
+
+	l.Info("x").String("a", "b").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{
+		"_l":    "info",
+		"_c":    "x",
+		"a":     "b",
+		"power": 9001,
+	})
+
+	l.Release()
+	l.Info("y").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{
+		"_l":    "info",
+		"_c":    "y",
+		"power": 9001,
+	})
+}
+
+func Test_JsonLogger_FixedAndMultiUse(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(128)(nil, INFO, true)
+
+	l.Field(NewField().String("f", "one").Finalize()).Fixed()
+	l.Field(NewField().Int("m", 2).Finalize()).MultiUse()
+	l.LogTo(out)
+	assert.Equal(t, out.String(), "{\"f\":\"one\",\"m\":2}\n")
+
+	out.Reset()
+
+	l.Error("e").String("a", "1").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{
+		"_l": "error",
+		"_c": "e",
+		"f":  "one",
+		"m":  2,
+		"a":  "1",
+	})
+
+	// the previous entry's data isn't repeated
+	l.Fatal("f").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{
+		"_l": "fatal",
+		"_c": "f",
+		"f":  "one",
+		"m":  2,
+	})
+
+	l.Release()
+
+	l.Fatal("f2").LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{
+		"_l": "fatal",
+		"_c": "f2",
+		"f":  "one",
+	})
+}
+
+func Test_JsonLogger_Field(t *testing.T) {
+	out := &strings.Builder{}
+	l := JsonFactory(128)(nil, INFO, true)
+
+	field := NewField().String("name", "leto \"II\"").Int("age", 3000).Finalize()
+	l.Info("i").Field(field).LogTo(out)
+	assertJsonLog(t, out, true, map[string]any{
+		"_l":   "info",
+		"_c":   "i",
+		"name": "leto \"II\"",
+		"age":  3000,
+	})
+}
+
+func assertJsonLog(t *testing.T, out *strings.Builder, strict bool, expected map[string]any) typed.Typed {
+	t.Helper()
+	line := out.String()
+	out.Reset()
+
+	if line == "" {
+		assert.Nil(t, expected)
+		return nil
+	}
+
+	assert.True(t, strings.HasSuffix(line, "}\n"))
+	assert.Equal(t, strings.Count(line, "\n"), 1)
+
+	lookup := typed.Must([]byte(line))
+	for expectedKey, expectedValue := range expected {
+		switch v := expectedValue.(type) {
+		case int:
+			assert.Equal(t, lookup.Int(expectedKey), v)
+		case bool:
+			assert.Equal(t, lookup.Bool(expectedKey), v)
+		default:
+			assert.Equal(t, lookup.String(expectedKey), v.(string))
+		}
+	}
+
+	if strict {
+		// -1 to remove the timestamp
+		assert.Equal(t, len(lookup)-1, len(expected))
+	}
+	return lookup
+}
+
+func Test_JsonLogger_Values(t *testing.T) {
+	defer func() { timeFormat = TimeRFC3339 }()
+
+	out := &strings.Builder{}
+	l := JsonFactory(256)(nil, INFO, true)
+	tm := time.Date(2023, 4, 5, 6, 7, 8, 9000000, time.UTC)
+
+	l.Info("i").
+		Float("f", 1.25).
+		Float("nan", math.NaN()).
+		Uint64("u", math.MaxUint64).
+		Time("t", tm).
+		Duration("d", 1500*time.Microsecond).
+		LogTo(out)
+
+	line := out.String()
+	assert.StringContains(t, line, `"f":1.25,"nan":"NaN","u":18446744073709551615,"t":"2023-04-05T06:07:08Z","d":"1.5ms"`)
+	assertJsonLog(t, out, false, nil)
+
+	timeFormat = TimeUnixMs
+	l.Info("i").Time("t", tm).LogTo(out)
+	assert.StringContains(t, out.String(), `"t":1680674828009}`)
+	assertJsonLog(t, out, false, map[string]any{"t": 1680674828009})
+}
@@ -48,6 +48,10 @@ type KvLogger struct {
 	// Whether or not we're logging request messages
 	requests bool
 
+	// The level of the entry being logged (NONE for request entries), used
+	// to pick which sinks the entry is written to
+	entry Level
+
 	// A logger can have a fixed piece of data which is
 	// always included (e.g pid=$PROJECT_ID for a project-owned
 	// logger). Once our fixed data is set, pos will never be
@@ -97,11 +101,17 @@ func (l *KvLogger) MultiUse() Logger {
 
 // Add a field (key=value) where value is a string
 func (l *KvLogger) String(key string, value string) Logger {
+	if redaction, ok := redaction(key); ok {
+		return l.redacted(key, redaction, utils.S2B(value))
+	}
 	l.writeKeyValue(key, value)
 	return l
 }
 
 func (l *KvLogger) Binary(key string, value []byte) Logger {
+	if redaction, ok := redaction(key); ok {
+		return l.redacted(key, redaction, value)
+	}
 	len := binaryEncoder.EncodedLen(len(value))
 	if l.writeKeyForValueLen(key, len) {
 		enc := base64.NewEncoder(binaryEncoder, l.buffer)
@@ -111,6 +121,12 @@ func (l *KvLogger) Binary(key string, value []byte) Logger {
 	return l
 }
 
+func (l *KvLogger) redacted(key string, redaction Redaction, value []byte) Logger {
+	var scratch [32]byte
+	l.writeRaw(key, appendRedacted(scratch[:0], redaction, value))
+	return l
+}
+
 // Add a field (key=value) where value is an int
 func (l *KvLogger) Int(key string, value int) Logger {
 	return l.Int64(key, int64(value))
@@ -137,45 +153,63 @@ func (l *KvLogger) Bool(key string, value bool) Logger {
 	return l
 }
 
-// Add a field (key=value) where value is an error
-func (l *KvLogger) Err(err error) Logger {
-	se, ok := err.(*StructuredError)
-	if !ok {
-		return l.String("_err", err.Error())
-	}
+// Add a field (key=value) where value is a float
+func (l *KvLogger) Float(key string, value float64) Logger {
+	var scratch [32]byte
+	l.writeRaw(key, appendFloat(scratch[:0], value))
+	return l
+}
 
-	l.Int("_code", se.Code).String("_err", se.Err.Error())
-	for key, value := range se.Data {
-		switch v := value.(type) {
-		case string:
-			l.String(key, v)
-		case int:
-			l.Int(key, v)
-		case []byte:
-			l.Binary(key, v)
-		}
-	}
+// Add a field (key=value) where value is an uint64
+func (l *KvLogger) Uint64(key string, value uint64) Logger {
+	var scratch [20]byte
+	l.writeRaw(key, appendUint64(scratch[:0], value))
+	return l
+}
+
+// Add a field (key=value) where value is a time
+func (l *KvLogger) Time(key string, value time.Time) Logger {
+	var scratch [40]byte
+	l.writeRaw(key, appendTime(scratch[:0], value))
 	return l
 }
 
-// Write the log to our globally configured writer
+// Add a field (key=value) where value is a duration
+func (l *KvLogger) Duration(key string, value time.Duration) Logger {
+	var scratch [32]byte
+	l.writeRaw(key, appendDuration(scratch[:0], value))
+	return l
+}
+
+// Add a field (key=value) where value is an error
+func (l *KvLogger) Err(err error) Logger {
+	return logErr(l, err)
+}
+
+// Write the log to our globally configured writer (or sinks)
 func (l *KvLogger) Log() {
-	l.LogTo(Out)
+	buffer := l.buffer
+	endKvEntry(buffer)
+	if !writeSinks(l.entry, buffer.OKBytes()) {
+		Out.Write(buffer.OKBytes())
+	}
+	l.conditionalRelease()
 }
 
 func (l *KvLogger) LogTo(out io.Writer) {
 	buffer := l.buffer
 
-	buffer.WriteByte('\n')
+	endKvEntry(buffer)
 	out.Write(buffer.OKBytes())
 	l.conditionalRelease()
 }
 
 func (l *KvLogger) Reset() {
-	l.buffer.Seek(l.fixedLen, io.SeekStart)
+	l.buffer.Rewind(int(l.fixedLen))
 }
 
 func (l *KvLogger) Release() {
+	l.multiUseLen = 0
 	l.buffer.Reset()
 	l.buffer.Seek(l.fixedLen, io.SeekStart)
 	if release := l.release; release != nil {
@@ -189,45 +223,55 @@ func (l *KvLogger) conditionalRelease() {
 	if l.multiUseLen == 0 {
 		l.Release()
 	} else {
-		// remove our trailing newline
-		l.buffer.Truncate(1)
+		// discard everything after our multi-use data (including our trailing
+		// newline) so that the next message starts fresh. Rewind (not Seek) so
+		// that an entry which overflowed doesn't leave the error set.
+		l.buffer.Rewind(int(l.multiUseLen))
 	}
 }
 
+// Ends the entry with a newline. Every field reserves space for it, but a
+// field which didn't fit leaves the buffer's error set, which would make
+// WriteByte a noop (merging this entry with the next).
+func endKvEntry(buffer *buffer.Buffer) {
+	buffer.Rewind(buffer.Len())
+	buffer.WriteByte('\n')
+}
+
 // Log an info-level message.
 func (l *KvLogger) Info(ctx string) Logger {
-	if l.level > INFO {
+	if !enabled(INFO, l.level, ctx) {
 		l.conditionalRelease()
 		return Noop{}
 	}
-	return l.start(ctx, []byte("_l=info _t="))
+	return l.start(ctx, INFO, []byte("_l=info _t="))
 }
 
 // Log an warn-level message.
 func (l *KvLogger) Warn(ctx string) Logger {
-	if l.level > WARN {
+	if !enabled(WARN, l.level, ctx) {
 		l.conditionalRelease()
 		return Noop{}
 	}
-	return l.start(ctx, []byte("_l=warn _t="))
+	return l.start(ctx, WARN, []byte("_l=warn _t="))
 }
 
 // Log an error-level message.
 func (l *KvLogger) Error(ctx string) Logger {
-	if l.level > ERROR {
+	if !enabled(ERROR, l.level, ctx) {
 		l.conditionalRelease()
 		return Noop{}
 	}
-	return l.start(ctx, []byte("_l=error _t="))
+	return l.start(ctx, ERROR, []byte("_l=error _t="))
 }
 
 // Log an fatal-level message.
 func (l *KvLogger) Fatal(ctx string) Logger {
-	if l.level > FATAL {
+	if !enabled(FATAL, l.level, ctx) {
 		l.conditionalRelease()
 		return Noop{}
 	}
-	return l.start(ctx, []byte("_l=fatal _t="))
+	return l.start(ctx, FATAL, []byte("_l=fatal _t="))
 }
 
 // Log a request message.
@@ -236,10 +280,13 @@ func (l *KvLogger) Request(route string) Logger {
 		l.conditionalRelease()
 		return Noop{}
 	}
-	return l.start(route, []byte("_l=req _t="))
+	return l.start(route, NONE, []byte("_l=req _t="))
 }
 
 func (l *KvLogger) Field(field Field) Logger {
+	if len(field.kv) == 0 {
+		return l
+	}
 	buffer := l.buffer
 
 	// might already have data
@@ -252,7 +299,8 @@ func (l *KvLogger) Field(field Field) Logger {
 
 // "starts" a new log message. Every message always contains a timestamp (t) a
 // context (c) and a level (l).
-func (l *KvLogger) start(ctx string, meta []byte) Logger {
+func (l *KvLogger) start(ctx string, level Level, meta []byte) Logger {
+	l.entry = level
 	buffer := l.buffer
 
 	// len > 0 when MultiUse is enabled
@@ -261,24 +309,46 @@ func (l *KvLogger) start(ctx string, meta []byte) Logger {
 	}
 
 	// includes the log level + the _t= key (but not the timestamp itself)
+	var scratch [20]byte
 	buffer.Write(meta)
-	buffer.WriteString(strconv.FormatInt(time.Now().Unix(), 10))
+	buffer.Write(strconv.AppendInt(scratch[:0], time.Now().Unix(), 10))
 	buffer.WriteString(" _c=")
 	buffer.WriteString(ctx)
+
+	if captureCaller(level) {
+		l.source(level)
+	}
 	return l
 }
 
+// Writes the caller (_src) and, for FATAL entries, the stack (_stack)
+func (l *KvLogger) source(level Level) {
+	var scratch [1024]byte
+	l.writeKeyValue("_src", utils.B2S(appendCaller(scratch[:0])))
+	if level == FATAL {
+		l.writeKeyValue("_stack", utils.B2S(appendStack(scratch[:0], 0)))
+	}
+}
+
 // Writes "$key=" and returns the position where the value can be written.
 func (l *KvLogger) writeKeyForValue(key string, value string) bool {
 	return l.writeKeyForValueLen(key, len(value))
 }
 
+// Writes a value which we know doesn't need to be escaped
+func (l *KvLogger) writeRaw(key string, value []byte) {
+	if l.writeKeyForValueLen(key, len(value)) {
+		l.buffer.Write(value)
+	}
+}
+
 // We expect key to always be safe to write as-is.
 func (l *KvLogger) writeKeyForValueLen(key string, valueLen int) bool {
 	return writeKeyForValueLen(key, valueLen, l.buffer)
 }
 
-// We only encode newline and quotes. If either is present, the value is quote encoded.
+// We only encode newline, quotes and backslashes. If any of these, a space
+// or an equal sign is present, the value is quote encoded.
 func (l *KvLogger) writeKeyValue(key string, value string) {
 	writeKeyValue(key, value, l.buffer)
 }
@@ -326,6 +396,9 @@ func writeKeyValue(key string, value string, buffer *buffer.Buffer) {
 		case '"':
 			buffer.WriteByteUnsafe('\\')
 			buffer.WriteByteUnsafe('"')
+		case '\\':
+			buffer.WriteByteUnsafe('\\')
+			buffer.WriteByteUnsafe('\\')
 		default:
 			buffer.WriteByteUnsafe(c)
 		}
@@ -338,7 +411,7 @@ func escapeCount(input string) int {
 	count := 0
 	for i := 0; i < len(input); i++ {
 		c := input[i]
-		if c == '=' || c == '"' || c == '\n' || c == ' ' {
+		if c == '=' || c == '"' || c == '\n' || c == ' ' || c == '\\' {
 			count += 1
 		}
 	}
@@ -3,6 +3,8 @@ package log
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"testing"
@@ -80,6 +82,19 @@ func Test_KvLogger_StructuredError_Data(t *testing.T) {
 	})
 }
 
+func Test_KvLogger_StructuredError_Wrapped(t *testing.T) {
+	out := &strings.Builder{}
+	l := KvFactory(128)(nil, INFO, true)
+	se := Err(313, errors.New("test_error3")).String("a", "z")
+
+	l.Warn("w").Err(fmt.Errorf("outer: %w", se)).LogTo(out)
+	assertKvLog(t, out, false, map[string]string{
+		"_code": "313",
+		"_err":  `"outer: code: 313 - test_error3"`,
+		"a":     "z",
+	})
+}
+
 func Test_KvLogger_StructuredError_Nesting_NoData(t *testing.T) {
 	out := &strings.Builder{}
 	l := KvFactory(128)(nil, INFO, true)
@@ -260,6 +275,46 @@ func Test_KvLogger_MultiUse_Common(t *testing.T) {
 	assert.Equal(t, len(fields), 3) // +1 for time
 }
 
+func Test_KvLogger_MultiUse_Entries(t *testing.T) {
+	out := &strings.Builder{}
+	l := KvFactory(128)(nil, INFO, true)
+	l.Field(NewField().String("rid", "r1").Finalize()).MultiUse()
+	l.LogTo(out)
+	out.Reset()
+
+	l.Info("a").String("x", "1").LogTo(out)
+	l.Warn("b").LogTo(out)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	assert.True(t, strings.HasPrefix(lines[0], "rid=r1 _l=info "))
+	assert.True(t, strings.HasPrefix(lines[1], "rid=r1 _l=warn "))
+	assert.False(t, strings.Contains(lines[1], "x=1"))
+
+	// released loggers are no longer multi-use
+	l.Release()
+	l.Info("c").LogTo(out)
+	assert.Equal(t, len(l.Bytes()), 0)
+}
+
+func Test_KvLogger_MultiUse_Overflow(t *testing.T) {
+	out := &strings.Builder{}
+	l := KvFactory(64)(nil, INFO, true)
+	l.Field(NewField().String("rid", "r1").Finalize()).MultiUse()
+	l.LogTo(out)
+	out.Reset()
+
+	l.Info("a").String("x", strings.Repeat("x", 100)).LogTo(out)
+	l.Warn("b").String("y", "1").LogTo(out)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	assert.True(t, strings.HasPrefix(lines[0], "rid=r1 _l=info "))
+	assert.False(t, strings.Contains(lines[0], "x="))
+	assert.True(t, strings.HasPrefix(lines[1], "rid=r1 _l=warn "))
+	assert.True(t, strings.HasSuffix(lines[1], " y=1"))
+}
+
 func Test_Logger_FixedAndMultiUse(t *testing.T) {
 	out := &strings.Builder{}
 	l := KvFactory(128)(nil, INFO, true)
@@ -325,3 +380,60 @@ func assertNoField(t *testing.T, out *strings.Builder, field string) {
 	_, exists := fields[field]
 	assert.False(t, exists)
 }
+
+func Test_KvLogger_Float(t *testing.T) {
+	out := &strings.Builder{}
+	l := KvFactory(128)(nil, INFO, true)
+
+	l.Info("i").Float("ms", 1.25).Float("z", 0).Float("neg", -3.5e-7).LogTo(out)
+	assertKvLog(t, out, false, map[string]string{"ms": "1.25", "z": "0", "neg": "-3.5e-07"})
+}
+
+func Test_KvLogger_Uint64(t *testing.T) {
+	out := &strings.Builder{}
+	l := KvFactory(128)(nil, INFO, true)
+
+	l.Info("i").Uint64("big", math.MaxUint64).LogTo(out)
+	assertKvLog(t, out, false, map[string]string{"big": "18446744073709551615"})
+}
+
+func Test_KvLogger_Time(t *testing.T) {
+	defer func() { timeFormat = TimeRFC3339 }()
+
+	out := &strings.Builder{}
+	l := KvFactory(128)(nil, INFO, true)
+	tm := time.Date(2023, 4, 5, 6, 7, 8, 9000000, time.UTC)
+
+	l.Info("i").Time("at", tm).LogTo(out)
+	assertKvLog(t, out, false, map[string]string{"at": "2023-04-05T06:07:08Z"})
+
+	timeFormat = TimeUnixMs
+	l.Info("i").Time("at", tm).LogTo(out)
+	assertKvLog(t, out, false, map[string]string{"at": "1680674828009"})
+}
+
+func Test_KvLogger_Duration(t *testing.T) {
+	out := &strings.Builder{}
+	l := KvFactory(128)(nil, INFO, true)
+
+	l.Info("i").Duration("took", 1500*time.Microsecond).LogTo(out)
+	assertKvLog(t, out, false, map[string]string{"took": "1.5ms"})
+}
+
+func Test_KvLogger_StructuredError_Values(t *testing.T) {
+	out := &strings.Builder{}
+	l := KvFactory(256)(nil, INFO, true)
+	se := Err(1, errors.New("e")).
+		Float("f", 2.5).
+		Uint64("u", 9).
+		Time("t", time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)).
+		Duration("d", time.Second)
+
+	l.Warn("w").Err(se).LogTo(out)
+	assertKvLog(t, out, false, map[string]string{
+		"f": "2.5",
+		"u": "9",
+		"t": "2023-04-05T06:07:08Z",
+		"d": "1s",
+	})
+}
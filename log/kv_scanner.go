@@ -0,0 +1,247 @@
+package log
+
+/*
+A streaming parser for the lines written by the KvLogger. Unlike KvParse
+(which only exists to help tests), this returns errors on malformed input,
+unescapes quoted values and is meant to be used on real log files.
+
+Values that contain a space, '=', '"', '\' or a newline are written within
+double quotes, with '"', '\' and newline escaped as \", \\ and \n. Any other
+value is written as-is. Keys are always written as-is.
+
+To avoid allocations, a parsed KvEntry references the line it was parsed from
+(and an internal scratch space for unescaped values). It's only valid until
+the next call to Parse (or KvScanner.Scan).
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+
+	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/buffer"
+)
+
+var (
+	ErrKvEmptyKey          = errors.New("empty key")
+	ErrKvMissingEquals     = errors.New("missing '=' after key")
+	ErrKvUnterminatedQuote = errors.New("unterminated quoted value")
+	ErrKvInvalidQuote      = errors.New("quoted value must be followed by a space")
+)
+
+type KvSyntaxError struct {
+	Err    error
+	Line   int
+	Offset int
+}
+
+func (e *KvSyntaxError) Error() string {
+	return "kv line " + strconv.Itoa(e.Line) + " at offset " + strconv.Itoa(e.Offset) + " - " + e.Err.Error()
+}
+
+func (e *KvSyntaxError) Unwrap() error {
+	return e.Err
+}
+
+type KvPair struct {
+	Key   []byte
+	Value []byte
+}
+
+type KvEntry struct {
+	pairs []KvPair
+
+	// unescaped quoted values are written here
+	scratch []byte
+}
+
+func (e *KvEntry) Pairs() []KvPair {
+	return e.pairs
+}
+
+// Returns the value of the key. If the key is repeated (which can happen
+// when fixed or multi-use data is overwritten), the last value wins.
+func (e *KvEntry) Get(key string) ([]byte, bool) {
+	pairs := e.pairs
+	for i := len(pairs) - 1; i >= 0; i-- {
+		if string(pairs[i].Key) == key {
+			return pairs[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// Parses a single line (with or without the trailing newline). On error, the
+// returned error is a *KvSyntaxError (with a Line of 0).
+func (e *KvEntry) Parse(line []byte) error {
+	line = bytes.TrimRight(line, "\n")
+
+	pairs := e.pairs[:0]
+	scratch := e.scratch[:0]
+
+	// unescaped values are never longer than the line, so scratch will never
+	// be re-allocated (which would invalidate the values we already parsed)
+	if cap(scratch) < len(line) {
+		scratch = make([]byte, 0, len(line))
+	}
+
+	i := 0
+	for i < len(line) {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+
+		keyStart := i
+		for i < len(line) && line[i] != '=' {
+			if c := line[i]; c == ' ' || c == '"' {
+				return kvSyntaxError(ErrKvMissingEquals, i)
+			}
+			i++
+		}
+		if i == len(line) {
+			return kvSyntaxError(ErrKvMissingEquals, i)
+		}
+		if i == keyStart {
+			return kvSyntaxError(ErrKvEmptyKey, i)
+		}
+		key := line[keyStart:i]
+		i++ // skip the '='
+
+		if i == len(line) || line[i] != '"' {
+			valueStart := i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			pairs = append(pairs, KvPair{Key: key, Value: line[valueStart:i]})
+			continue
+		}
+
+		quoteStart := i
+		i++ // skip the opening quote
+		valueStart := len(scratch)
+
+		closed := false
+		for i < len(line) {
+			c := line[i]
+			if c == '"' {
+				closed = true
+				i++
+				break
+			}
+			if c == '\\' && i+1 < len(line) {
+				i++
+				switch n := line[i]; n {
+				case 'n':
+					scratch = append(scratch, '\n')
+				case '"', '\\':
+					scratch = append(scratch, n)
+				default:
+					scratch = append(scratch, '\\', n)
+				}
+			} else {
+				scratch = append(scratch, c)
+			}
+			i++
+		}
+
+		if !closed {
+			return kvSyntaxError(ErrKvUnterminatedQuote, quoteStart)
+		}
+		if i < len(line) && line[i] != ' ' {
+			return kvSyntaxError(ErrKvInvalidQuote, i)
+		}
+		pairs = append(pairs, KvPair{Key: key, Value: scratch[valueStart:len(scratch):len(scratch)]})
+	}
+
+	e.pairs = pairs
+	e.scratch = scratch
+	return nil
+}
+
+// Writes the entry as a JSON object (without a trailing newline). All values
+// are written as strings.
+func (e *KvEntry) WriteJSON(buffer *buffer.Buffer) {
+	start := buffer.Len()
+	buffer.WriteByte('{')
+	for _, pair := range e.pairs {
+		if buffer.Len() > start+1 {
+			buffer.WriteByte(',')
+		}
+		writeJsonString(utils.B2S(pair.Key), buffer)
+		buffer.WriteByte(':')
+		writeJsonString(utils.B2S(pair.Value), buffer)
+	}
+	buffer.WriteByte('}')
+}
+
+func kvSyntaxError(err error, offset int) error {
+	return &KvSyntaxError{Err: err, Offset: offset}
+}
+
+type KvScanner struct {
+	scanner *bufio.Scanner
+	entry   KvEntry
+	err     error
+	line    int
+}
+
+// maxLineSize is the largest line that can be read. Larger lines stop the
+// scanner with bufio.ErrTooLong.
+func NewKvScanner(r io.Reader, maxLineSize int) *KvScanner {
+	scanner := bufio.NewScanner(r)
+	initial := 65536
+	if maxLineSize < initial {
+		initial = maxLineSize
+	}
+	scanner.Buffer(make([]byte, initial), maxLineSize)
+	return &KvScanner{scanner: scanner}
+}
+
+// Advances to the next non-empty line. Returns false at the end of the input
+// or when reading fails (see Err). A malformed line does not stop the
+// scanner, see Entry.
+func (s *KvScanner) Scan() bool {
+	for s.scanner.Scan() {
+		s.line++
+		line := s.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		s.err = s.entry.Parse(line)
+		if se, ok := s.err.(*KvSyntaxError); ok {
+			se.Line = s.line
+		}
+		return true
+	}
+	s.err = nil
+	return false
+}
+
+// The entry parsed by the last call to Scan, or the *KvSyntaxError describing
+// why the line couldn't be parsed. Only valid until the next call to Scan.
+func (s *KvScanner) Entry() (*KvEntry, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &s.entry, nil
+}
+
+// The raw line read by the last call to Scan. Only valid until the next call
+// to Scan.
+func (s *KvScanner) Bytes() []byte {
+	return s.scanner.Bytes()
+}
+
+// The 1-based line number of the last scanned line
+func (s *KvScanner) Line() int {
+	return s.line
+}
+
+// The error (if any) which caused Scan to return false
+func (s *KvScanner) Err() error {
+	return s.scanner.Err()
+}
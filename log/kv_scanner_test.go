@@ -0,0 +1,123 @@
+package log
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/buffer"
+)
+
+func Test_KvEntry_Parse(t *testing.T) {
+	entry := new(KvEntry)
+	assert.Nil(t, entry.Parse([]byte(`_l=info _c=x a=1 b="hello world" c= d="a\"b\\c\nd" e="x=y"`+"\n")))
+	assertKvPairs(t, entry, "_l", "info", "_c", "x", "a", "1", "b", "hello world", "c", "", "d", "a\"b\\c\nd", "e", "x=y")
+
+	// entries are re-used
+	assert.Nil(t, entry.Parse([]byte(`a="1 2" b=3`)))
+	assertKvPairs(t, entry, "a", "1 2", "b", "3")
+
+	assert.Nil(t, entry.Parse([]byte("")))
+	assertKvPairs(t, entry)
+
+	// unknown escapes are kept as-is
+	assert.Nil(t, entry.Parse([]byte(`a="1\t"`)))
+	assertKvPairs(t, entry, "a", `1\t`)
+}
+
+func Test_KvEntry_Parse_Errors(t *testing.T) {
+	entry := new(KvEntry)
+	assertKvSyntaxError(t, entry.Parse([]byte("a=1 b")), ErrKvMissingEquals, 5)
+	assertKvSyntaxError(t, entry.Parse([]byte("a b=1")), ErrKvMissingEquals, 1)
+	assertKvSyntaxError(t, entry.Parse([]byte("a=1 =2")), ErrKvEmptyKey, 4)
+	assertKvSyntaxError(t, entry.Parse([]byte(`a="1 2`)), ErrKvUnterminatedQuote, 2)
+	assertKvSyntaxError(t, entry.Parse([]byte(`a="1\"`)), ErrKvUnterminatedQuote, 2)
+	assertKvSyntaxError(t, entry.Parse([]byte(`a="1"b c=2`)), ErrKvInvalidQuote, 5)
+}
+
+func Test_KvEntry_Get(t *testing.T) {
+	entry := new(KvEntry)
+	entry.Parse([]byte("a=1 b=2 a=3"))
+
+	value, ok := entry.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, string(value), "3")
+
+	_, ok = entry.Get("c")
+	assert.False(t, ok)
+}
+
+func Test_KvEntry_WriteJSON(t *testing.T) {
+	entry := new(KvEntry)
+	entry.Parse([]byte(`a=1 b="x \"y\"" c=`))
+
+	b := buffer.New(64, 64)
+	entry.WriteJSON(b)
+	assert.Equal(t, b.MustString(), `{"a":"1","b":"x \"y\"","c":""}`)
+}
+
+// Whatever the logger writes, we can parse back
+func Test_KvEntry_Parse_RoundTrip(t *testing.T) {
+	values := []string{"", "a", "a b", "a=b", `"`, `\`, `a\"`, `x \`, "\n", "\\n", `\\" x`}
+
+	out := &strings.Builder{}
+	l := KvFactory(256)(nil, INFO, true)
+	entry := new(KvEntry)
+	for _, value := range values {
+		l.Info("rt").String("v", value).LogTo(out)
+		assert.Nil(t, entry.Parse([]byte(out.String())))
+		actual, _ := entry.Get("v")
+		assert.Equal(t, string(actual), value)
+		out.Reset()
+	}
+}
+
+func Test_KvScanner(t *testing.T) {
+	input := "a=1 b=2\n\nbad\nc=\"x y\"\n"
+	s := NewKvScanner(strings.NewReader(input), 1024)
+
+	assert.True(t, s.Scan())
+	entry, err := s.Entry()
+	assert.Nil(t, err)
+	assertKvPairs(t, entry, "a", "1", "b", "2")
+	assert.Equal(t, s.Line(), 1)
+
+	assert.True(t, s.Scan())
+	_, err = s.Entry()
+	assertKvSyntaxError(t, err, ErrKvMissingEquals, 3)
+	assert.Equal(t, err.(*KvSyntaxError).Line, 3)
+	assert.Equal(t, err.Error(), "kv line 3 at offset 3 - missing '=' after key")
+	assert.Equal(t, string(s.Bytes()), "bad")
+
+	assert.True(t, s.Scan())
+	entry, err = s.Entry()
+	assert.Nil(t, err)
+	assertKvPairs(t, entry, "c", "x y")
+	assert.Equal(t, s.Line(), 4)
+
+	assert.False(t, s.Scan())
+	assert.Nil(t, s.Err())
+}
+
+func Test_KvScanner_TooLong(t *testing.T) {
+	s := NewKvScanner(strings.NewReader("a=1234567890\n"), 8)
+	assert.False(t, s.Scan())
+	assert.NotNil(t, s.Err())
+}
+
+func assertKvPairs(t *testing.T, entry *KvEntry, expected ...string) {
+	t.Helper()
+	pairs := entry.Pairs()
+	assert.Equal(t, len(pairs), len(expected)/2)
+	for i, pair := range pairs {
+		assert.Equal(t, string(pair.Key), expected[i*2])
+		assert.Equal(t, string(pair.Value), expected[i*2+1])
+	}
+}
+
+func assertKvSyntaxError(t *testing.T, err error, expected error, offset int) {
+	t.Helper()
+	assert.True(t, errors.Is(err, expected))
+	assert.Equal(t, err.(*KvSyntaxError).Offset, offset)
+}
@@ -0,0 +1,114 @@
+package log
+
+/*
+Level overrides allow specific contexts (the value passed to Info/Warn/
+Error/Fatal) to be logged at a different level than the configured level.
+For example, with a configured level of WARN and an override of
+"migration_*" => INFO, Info("migration_applied") would be logged while
+Info("user_created") would not.
+
+An override's key is either an exact context or a prefix followed by '*'.
+An exact match wins over a prefix and a longer prefix wins over a shorter one.
+
+Overrides are global and swapped atomically, so they can be changed at
+runtime without rebuilding the pool. When no overrides are set, checking the
+level costs a single atomic load.
+*/
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"src.goblgobl.com/utils"
+)
+
+var overrides atomic.Pointer[levelOverrides]
+
+type levelOverrides struct {
+	exact map[string]Level
+
+	// sorted by prefix length, longest first
+	prefixes []prefixLevel
+}
+
+type prefixLevel struct {
+	prefix string
+	level  Level
+}
+
+// Replaces any existing overrides. The map is context (or prefix*) => level
+// name. A nil or empty map removes all overrides.
+func SetLevels(levels map[string]string) error {
+	o, err := parseLevels(levels)
+	if err != nil {
+		return err
+	}
+	overrides.Store(o)
+	return nil
+}
+
+// nil when there are no overrides
+func parseLevels(levels map[string]string) (*levelOverrides, error) {
+	if len(levels) == 0 {
+		return nil, nil
+	}
+
+	o := &levelOverrides{exact: make(map[string]Level)}
+	for ctx, name := range levels {
+		level, ok := parseLevel(name)
+		if !ok {
+			return nil, Errf(utils.ERR_INVALID_LOG_LEVEL, "log.levels.%s is invalid. Should be one of: INFO, WARN, ERROR, FATAL or NONE", ctx)
+		}
+
+		if prefix, isPrefix := strings.CutSuffix(ctx, "*"); isPrefix {
+			o.prefixes = append(o.prefixes, prefixLevel{prefix: prefix, level: level})
+		} else {
+			o.exact[ctx] = level
+		}
+	}
+
+	sort.Slice(o.prefixes, func(i, j int) bool {
+		return len(o.prefixes[i].prefix) > len(o.prefixes[j].prefix)
+	})
+	return o, nil
+}
+
+// Whether an entry of the given level and context should be logged by a
+// logger (or pool) configured with the given level
+func enabled(level Level, configured Level, ctx string) bool {
+	if o := overrides.Load(); o != nil {
+		if override, ok := o.lookup(ctx); ok {
+			configured = override
+		}
+	}
+	return level >= configured
+}
+
+func (o *levelOverrides) lookup(ctx string) (Level, bool) {
+	if level, ok := o.exact[ctx]; ok {
+		return level, true
+	}
+	for _, p := range o.prefixes {
+		if strings.HasPrefix(ctx, p.prefix) {
+			return p.level, true
+		}
+	}
+	return 0, false
+}
+
+func parseLevel(name string) (Level, bool) {
+	switch strings.ToUpper(name) {
+	case "INFO":
+		return INFO, true
+	case "WARN":
+		return WARN, true
+	case "ERROR":
+		return ERROR, true
+	case "FATAL":
+		return FATAL, true
+	case "NONE":
+		return NONE, true
+	}
+	return 0, false
+}
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"strings"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_SetLevels_Invalid(t *testing.T) {
+	err := SetLevels(map[string]string{"migration_*": "loud"})
+	assert.Equal(t, err.Error(), "code: 3001 - log.levels.migration_* is invalid. Should be one of: INFO, WARN, ERROR, FATAL or NONE")
+	assert.True(t, overrides.Load() == nil)
+}
+
+func Test_SetLevels_Pool(t *testing.T) {
+	defer SetLevels(nil)
+
+	p := NewPool(8, WARN, true, KvFactory(64), nil)
+	assertLevel := func(l Logger, expected bool) {
+		t.Helper()
+		_, ok := l.(*KvLogger)
+		assert.Equal(t, ok, expected)
+		l.Release()
+	}
+
+	assertLevel(p.Info("migration_applied"), false)
+	assertLevel(p.Warn("migration_applied"), true)
+
+	err := SetLevels(map[string]string{
+		"migration_*":      "info",
+		"migration_fail*":  "fatal",
+		"migration_failed": "warn",
+		"noisy":            "none",
+	})
+	assert.Nil(t, err)
+
+	assertLevel(p.Info("migration_applied"), true)
+	assertLevel(p.Info("migration"), false)
+	assertLevel(p.Error("migration_fail_x"), false)
+	assertLevel(p.Fatal("migration_fail_x"), true)
+	assertLevel(p.Info("migration_failed"), false)
+	assertLevel(p.Warn("migration_failed"), true)
+	assertLevel(p.Fatal("noisy"), false)
+	assertLevel(p.Info("other"), false)
+	assertLevel(p.Warn("other"), true)
+
+	SetLevels(nil)
+	assertLevel(p.Info("migration_applied"), false)
+	assertLevel(p.Fatal("noisy"), true)
+}
+
+func Test_SetLevels_Logger(t *testing.T) {
+	defer SetLevels(nil)
+
+	out := &strings.Builder{}
+	SetLevels(map[string]string{"sub_*": "info", "quiet": "none"})
+
+	for _, l := range []Logger{KvFactory(128)(nil, ERROR, true), JsonFactory(128)(nil, ERROR, true)} {
+		l.Info("sub_a").LogTo(out)
+		assert.StringContains(t, out.String(), "sub_a")
+		out.Reset()
+
+		l.Info("other").LogTo(out)
+		assert.Equal(t, out.String(), "")
+
+		l.Fatal("quiet").LogTo(out)
+		assert.Equal(t, out.String(), "")
+	}
+}
+
+func Test_Configure_Levels(t *testing.T) {
+	defer SetLevels(nil)
+
+	err := Configure(Config{Levels: map[string]string{"x": "invalid"}})
+	assert.Equal(t, err.Error(), "code: 3001 - log.levels.x is invalid. Should be one of: INFO, WARN, ERROR, FATAL or NONE")
+
+	err = Configure(Config{Level: "warn", Levels: map[string]string{"migration_*": "info"}})
+	assert.Nil(t, err)
+	assert.True(t, enabled(INFO, WARN, "migration_applied"))
+	assert.False(t, enabled(INFO, WARN, "other"))
+
+	err = Configure(Config{Level: "warn"})
+	assert.Nil(t, err)
+	assert.False(t, enabled(INFO, WARN, "migration_applied"))
+}
@@ -3,12 +3,17 @@ package log
 import (
 	"io"
 	"os"
+	"time"
 )
 
 var (
 	Out io.Writer = os.Stderr
 
 	globalPool *Pool
+
+	// set when Configure is called with an Async config, in which case
+	// this is also what Out is set to
+	globalAsync *AsyncWriter
 )
 
 func init() {
@@ -47,6 +52,32 @@ func Detach() Logger {
 	return globalPool.Detach()
 }
 
+// Blocks until all entries logged so far have been written. Only meaningful
+// when async logging is configured.
+func Flush() {
+	if async := globalAsync; async != nil {
+		async.Flush()
+	}
+	currentSinks().flush()
+}
+
+// Closes and re-opens every file sink, for use with external log rotation
+// (see Config.ReopenOnSighup).
+func Reopen() error {
+	return currentSinks().reopen()
+}
+
+// Drains and stops async logging (if configured), including that of any
+// sinks. Subsequent entries are written directly to the underlying writers.
+// Meant to be called on shutdown.
+func Close() error {
+	currentSinks().closeAsync()
+	if async := globalAsync; async != nil {
+		return async.Close()
+	}
+	return nil
+}
+
 type Logger interface {
 	// Actually log the data to the configured output
 	// If the logger was not configured for MultiUse, this
@@ -106,6 +137,19 @@ type Logger interface {
 	// Add a boolean value to the current entry
 	Bool(key string, value bool) Logger
 
+	// Add a float value to the current entry
+	Float(key string, value float64) Logger
+
+	// Add an uint64 value to the current entry
+	Uint64(key string, value uint64) Logger
+
+	// Add a time value to the current entry. Written as either RFC3339 or
+	// unix milliseconds, depending on the configured time format.
+	Time(key string, value time.Time) Logger
+
+	// Add a duration value to the current entry (e.g. 1.5ms)
+	Duration(key string, value time.Duration) Logger
+
 	// Log a field
 	Field(field Field) Logger
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"src.goblgobl.com/tests/assert"
 )
@@ -29,6 +30,10 @@ func Test_Noop_Is_Noop(t *testing.T) {
 		String("s", "s").
 		Int("i", 1).
 		Int64("i64", 99).
+		Float("f", 1.5).
+		Uint64("u", 2).
+		Time("t", time.Now()).
+		Duration("d", time.Second).
 		LogTo(out)
 	assert.Equal(t, out.String(), "")
 
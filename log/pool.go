@@ -22,6 +22,7 @@ type Pool struct {
 	level    Level
 	requests bool
 	factory  Factory
+	sampler  *Sampler
 }
 
 func NewPool(count uint16, level Level, requests bool, factory Factory, field *Field) *Pool {
@@ -44,34 +45,48 @@ func pooledLoggerFactory(factory Factory, level Level, requests bool, field *Fie
 	}
 }
 
+// Enables sampling of INFO, WARN and request entries (see Sampler). Must be
+// called before the pool is used.
+func (p *Pool) SetSampler(sampler *Sampler) {
+	p.sampler = sampler
+}
+
 // Creates a Logger detached from the pool (but using the pool's configuration)
 func (p *Pool) Detach() Logger {
 	return p.factory(nil, p.level, p.requests)
 }
 
 func (p *Pool) Info(ctx string) Logger {
-	if p.level > INFO {
+	if !enabled(INFO, p.level, ctx) {
+		return Noop{}
+	}
+	keep, dropped := p.sample(ctx)
+	if !keep {
 		return Noop{}
 	}
-	return p.Checkout().Info(ctx)
+	return sampled(p.Checkout().Info(ctx), dropped)
 }
 
 func (p *Pool) Warn(ctx string) Logger {
-	if p.level > WARN {
+	if !enabled(WARN, p.level, ctx) {
 		return Noop{}
 	}
-	return p.Checkout().Warn(ctx)
+	keep, dropped := p.sample(ctx)
+	if !keep {
+		return Noop{}
+	}
+	return sampled(p.Checkout().Warn(ctx), dropped)
 }
 
 func (p *Pool) Error(ctx string) Logger {
-	if p.level > ERROR {
+	if !enabled(ERROR, p.level, ctx) {
 		return Noop{}
 	}
 	return p.Checkout().Error(ctx)
 }
 
 func (p *Pool) Fatal(ctx string) Logger {
-	if p.level > FATAL {
+	if !enabled(FATAL, p.level, ctx) {
 		return Noop{}
 	}
 	return p.Checkout().Fatal(ctx)
@@ -81,5 +96,24 @@ func (p *Pool) Request(route string) Logger {
 	if !p.requests {
 		return Noop{}
 	}
-	return p.Checkout().Request(route)
+	keep, dropped := p.sample(route)
+	if !keep {
+		return Noop{}
+	}
+	return sampled(p.Checkout().Request(route), dropped)
+}
+
+// Always keeps the entry when sampling isn't enabled
+func (p *Pool) sample(ctx string) (bool, uint64) {
+	if sampler := p.sampler; sampler != nil {
+		return sampler.Sample(ctx)
+	}
+	return true, 0
+}
+
+func sampled(logger Logger, dropped uint64) Logger {
+	if dropped > 0 {
+		logger.Int64("sampled", int64(dropped))
+	}
+	return logger
 }
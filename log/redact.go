@@ -0,0 +1,189 @@
+package log
+
+/*
+Redaction rules replace the value of sensitive keys (passwords, tokens, ...)
+before they're written. A rule's key is either an exact key, a prefix
+followed by '*' ("secret_*"), a suffix preceded by '*' ("*_token") or a
+substring surrounded by '*' ("*password*"). Keys are matched case-sensitively.
+
+A value is either masked, which keeps its length ([redacted:12]), or hashed,
+which keeps a short HMAC-SHA256 prefix ([hmac:9f86d081]) so that the same
+value can be correlated across entries without being revealed. The HMAC is
+keyed (with a random per-process key, unless one is set with
+SetRedactionKey) so that short values (PINs, passwords) can't be brute-forced
+from the logs.
+
+Rules apply to String and Binary values, including StructuredError data.
+Fields are redacted when they're finalized, so Fields created before
+Configure (or SetRedactions) is called aren't affected.
+
+Like level overrides, rules are global and swapped atomically. When no rules
+are set, checking a key costs a single atomic load.
+*/
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"src.goblgobl.com/utils"
+)
+
+type Redaction uint8
+
+const (
+	RedactMask Redaction = iota
+	RedactHash
+)
+
+var (
+	redactions atomic.Pointer[redactionRules]
+
+	// HMACs keyed with the current redaction key
+	redactionHashers atomic.Pointer[sync.Pool]
+
+	// used when no key is set
+	processRedactionKey []byte
+)
+
+func init() {
+	processRedactionKey = make([]byte, 32)
+	if _, err := rand.Read(processRedactionKey); err != nil {
+		panic(err)
+	}
+	SetRedactionKey(nil)
+}
+
+type redactionRules struct {
+	exact    map[string]Redaction
+	patterns []redactionPattern
+}
+
+type redactionPattern struct {
+	value     string
+	prefix    bool
+	suffix    bool
+	redaction Redaction
+}
+
+// Replaces any existing rules. The map is key (or pattern) => "mask" or
+// "hash". A nil or empty map removes all rules.
+func SetRedactions(rules map[string]string) error {
+	r, err := parseRedactions(rules)
+	if err != nil {
+		return err
+	}
+	redactions.Store(r)
+	return nil
+}
+
+// nil when there are no rules
+func parseRedactions(rules map[string]string) (*redactionRules, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	r := &redactionRules{exact: make(map[string]Redaction)}
+	for key, name := range rules {
+		var redaction Redaction
+		switch strings.ToUpper(name) {
+		case "MASK":
+			redaction = RedactMask
+		case "HASH":
+			redaction = RedactHash
+		default:
+			return nil, Errf(utils.ERR_INVALID_LOG_REDACTION, "log.redact.%s is invalid. Should be one of: mask or hash", key)
+		}
+
+		value, suffix := strings.CutPrefix(key, "*")
+		value, prefix := strings.CutSuffix(value, "*")
+		if value == "" {
+			return nil, Errf(utils.ERR_INVALID_LOG_REDACTION, "log.redact.%s is invalid. Pattern must contain a key", key)
+		}
+
+		if prefix || suffix {
+			r.patterns = append(r.patterns, redactionPattern{
+				value:     value,
+				prefix:    prefix,
+				suffix:    suffix,
+				redaction: redaction,
+			})
+		} else {
+			r.exact[key] = redaction
+		}
+	}
+
+	return r, nil
+}
+
+// Sets the key which hashed values are keyed with. Setting the same key in
+// every process lets hashes be correlated across processes (and restarts).
+// An empty key restores the random per-process key.
+func SetRedactionKey(key []byte) {
+	if len(key) == 0 {
+		key = processRedactionKey
+	} else {
+		key = append([]byte(nil), key...)
+	}
+	redactionHashers.Store(&sync.Pool{
+		New: func() any {
+			return hmac.New(sha256.New, key)
+		},
+	})
+}
+
+// Whether (and how) the value of key should be redacted
+func redaction(key string) (Redaction, bool) {
+	r := redactions.Load()
+	if r == nil {
+		return 0, false
+	}
+
+	if redaction, ok := r.exact[key]; ok {
+		return redaction, true
+	}
+
+	for _, p := range r.patterns {
+		var match bool
+		switch {
+		case p.prefix && p.suffix:
+			match = strings.Contains(key, p.value)
+		case p.prefix:
+			match = strings.HasPrefix(key, p.value)
+		default:
+			match = strings.HasSuffix(key, p.value)
+		}
+		if match {
+			return p.redaction, true
+		}
+	}
+	return 0, false
+}
+
+// Appends the redacted form of value. The result never needs to be escaped
+// (in either KV or JSON).
+func appendRedacted(dst []byte, redaction Redaction, value []byte) []byte {
+	if redaction == RedactHash {
+		hashers := redactionHashers.Load()
+		h := hashers.Get().(hash.Hash)
+		h.Reset()
+		h.Write(value)
+		var scratch [sha256.Size]byte
+		sum := h.Sum(scratch[:0])
+		hashers.Put(h)
+
+		dst = append(dst, "[hmac:"...)
+		for _, b := range sum[:4] {
+			dst = append(dst, hex[b>>4], hex[b&0xF])
+		}
+	} else {
+		dst = append(dst, "[redacted:"...)
+		dst = strconv.AppendInt(dst, int64(len(value)), 10)
+	}
+	return append(dst, ']')
+}
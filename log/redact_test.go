@@ -0,0 +1,133 @@
+package log
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_SetRedactions_Invalid(t *testing.T) {
+	err := SetRedactions(map[string]string{"password": "hide"})
+	assert.Equal(t, err.Error(), "code: 3008 - log.redact.password is invalid. Should be one of: mask or hash")
+
+	err = SetRedactions(map[string]string{"**": "mask"})
+	assert.Equal(t, err.Error(), "code: 3008 - log.redact.** is invalid. Pattern must contain a key")
+}
+
+func Test_Redaction_Lookup(t *testing.T) {
+	defer SetRedactions(nil)
+
+	_, ok := redaction("password")
+	assert.False(t, ok)
+
+	SetRedactions(map[string]string{
+		"password":  "mask",
+		"secret_*":  "hash",
+		"*_token":   "hash",
+		"*authori*": "mask",
+	})
+
+	assertRedaction(t, "password", RedactMask)
+	assertRedaction(t, "secret_key", RedactHash)
+	assertRedaction(t, "access_token", RedactHash)
+	assertRedaction(t, "x_authorization", RedactMask)
+
+	for _, key := range []string{"passwords", "Password", "secret", "token", "user"} {
+		_, ok := redaction(key)
+		assert.False(t, ok)
+	}
+}
+
+func Test_KvLogger_Redaction(t *testing.T) {
+	defer SetRedactions(nil)
+	defer SetRedactionKey(nil)
+	SetRedactionKey([]byte("k"))
+	SetRedactions(map[string]string{"password": "mask", "*_token": "hash"})
+
+	out := &strings.Builder{}
+	l := KvFactory(256)(nil, INFO, true)
+	l.Info("i").
+		String("password", "hunter2 spaces").
+		String("api_token", "test").
+		Binary("refresh_token", []byte("test")).
+		String("user", "leto").
+		LogTo(out)
+
+	assertKvLog(t, out, false, map[string]string{
+		"password":      "[redacted:14]",
+		"api_token":     "[hmac:f90c794a]",
+		"refresh_token": "[hmac:f90c794a]",
+		"user":          "leto",
+	})
+}
+
+func Test_JsonLogger_Redaction(t *testing.T) {
+	defer SetRedactions(nil)
+	defer SetRedactionKey(nil)
+	SetRedactionKey([]byte("k"))
+	SetRedactions(map[string]string{"password": "mask", "*_token": "hash"})
+
+	out := &strings.Builder{}
+	l := JsonFactory(256)(nil, INFO, true)
+	l.Info("i").
+		String("password", "hunter2").
+		Binary("api_token", []byte("test")).
+		LogTo(out)
+
+	assertJsonLog(t, out, false, map[string]any{
+		"password":  "[redacted:7]",
+		"api_token": "[hmac:f90c794a]",
+	})
+}
+
+func Test_Field_Redaction(t *testing.T) {
+	defer SetRedactions(nil)
+	SetRedactions(map[string]string{"password": "mask"})
+
+	f := NewField().String("password", "hunter2").Finalize()
+	assert.Equal(t, string(f.KV()), "password=[redacted:7]")
+	assert.Equal(t, string(f.JSON()), `"password":"[redacted:7]"`)
+}
+
+func Test_StructuredError_Redaction(t *testing.T) {
+	defer SetRedactions(nil)
+	SetRedactions(map[string]string{"token": "mask"})
+
+	out := &strings.Builder{}
+	l := KvFactory(256)(nil, INFO, true)
+	l.Error("e").Err(Err(1, errors.New("e")).String("token", "abc")).LogTo(out)
+	assertKvLog(t, out, false, map[string]string{
+		"_code": "1",
+		"token": "[redacted:3]",
+	})
+}
+
+func assertRedaction(t *testing.T, key string, expected Redaction) {
+	t.Helper()
+	actual, ok := redaction(key)
+	assert.True(t, ok)
+	assert.Equal(t, actual, expected)
+}
+
+func Test_Redaction_Key(t *testing.T) {
+	defer SetRedactionKey(nil)
+
+	hashed := func() string {
+		return string(appendRedacted(nil, RedactHash, []byte("1234")))
+	}
+
+	random := hashed()
+	assert.Equal(t, len(random), 15)
+	assert.Equal(t, hashed(), random)
+
+	key := []byte("k")
+	SetRedactionKey(key)
+	key[0] = 'x'
+	assert.Equal(t, string(appendRedacted(nil, RedactHash, []byte("test"))), "[hmac:f90c794a]")
+	assert.NotEqual(t, hashed(), random)
+
+	SetRedactionKey(nil)
+	assert.Equal(t, hashed(), random)
+}
@@ -0,0 +1,315 @@
+package log
+
+/*
+A file that entries can be written to (typically via a Sink), which is
+optionally rotated once it reaches a maximum size or has been open for a
+given interval.
+
+On rotation, the current file is renamed to $name-$timestamp$ext (e.g.
+app-2023-04-05T06-07-08.000.log, in UTC) and a new file is opened at the
+original path. A background goroutine then optionally gzips the rotated
+files and deletes those which exceed maxBackups or are older than maxAge.
+
+Writes are serialized and each Write is written in a single call while
+holding the lock, so concurrent entries never interleave and a rotation
+never splits an entry across files.
+
+For external rotation (e.g. logrotate), Reopen closes and re-opens the
+file at the original path (see the package-level Reopen and
+Config.ReopenOnSighup).
+*/
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+type RotatingFile struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+
+	// bytes written to the current file
+	size uint64
+
+	// when the current file was opened
+	opened time.Time
+
+	// the timestamp of the last backup
+	lastRotated time.Time
+
+	// 0 disables size-based rotation
+	maxSize uint64
+
+	// 0 disables time-based rotation
+	interval time.Duration
+
+	// rotated files older than this are deleted (0 keeps them regardless of age)
+	maxAge time.Duration
+
+	// number of rotated files to keep (0 keeps them all)
+	maxBackups uint16
+
+	// whether rotated files are gzipped
+	compress bool
+
+	// signals our background goroutine that a rotation happened
+	rotated chan struct{}
+
+	// closed by our background goroutine once it has stopped
+	done chan struct{}
+
+	// swappable for tests
+	now func() time.Time
+}
+
+func NewRotatingFile(path string, maxSize uint64, interval time.Duration, maxAge time.Duration, maxBackups uint16, compress bool) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		interval:   interval,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		compress:   compress,
+		rotated:    make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		now:        time.Now,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	go f.run()
+	return f, nil
+}
+
+// io.Writer. data is expected to be one or more complete entries.
+func (f *RotatingFile) Write(data []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.shouldRotate(len(data)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(data)
+	f.size += uint64(n)
+	return n, err
+}
+
+// Closes and re-opens the file at the configured path. Meant to be called
+// after the file was moved by an external tool.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	f.file.Close()
+	return f.open()
+}
+
+// io.Closer. Closes the file and waits for any pending compression or
+// cleanup of rotated files to finish.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	file := f.file
+	f.file = nil
+	f.mu.Unlock()
+
+	if file == nil {
+		return nil
+	}
+
+	err := file.Close()
+	close(f.rotated)
+	<-f.done
+	return err
+}
+
+func (f *RotatingFile) shouldRotate(dataLen int) bool {
+	if f.size == 0 {
+		// never rotate an empty file, even if the entry alone exceeds maxSize
+		return false
+	}
+	if maxSize := f.maxSize; maxSize > 0 && f.size+uint64(dataLen) > maxSize {
+		return true
+	}
+	if interval := f.interval; interval > 0 && f.now().Sub(f.opened) >= interval {
+		return true
+	}
+	return false
+}
+
+// called with the lock held
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	// backups are named using a millisecond timestamp, make sure rapid
+	// rotations don't overwrite each other
+	rotated := f.now().Truncate(time.Millisecond)
+	if !rotated.After(f.lastRotated) {
+		rotated = f.lastRotated.Add(time.Millisecond)
+	}
+	f.lastRotated = rotated
+
+	if err := os.Rename(f.path, f.backupPath(rotated)); err != nil {
+		// keep writing to the current file rather than losing entries
+		return f.open()
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	select {
+	case f.rotated <- struct{}{}:
+	default:
+		// our background goroutine already has a pending rotation
+	}
+	return nil
+}
+
+// called with the lock held (or from the constructor)
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = uint64(info.Size())
+	f.opened = f.now()
+	return nil
+}
+
+func (f *RotatingFile) run() {
+	defer close(f.done)
+	for range f.rotated {
+		f.cleanup()
+	}
+}
+
+// Compresses and deletes rotated files, as configured. Errors are ignored,
+// the next rotation will try again.
+func (f *RotatingFile) cleanup() {
+	backups := f.backups()
+
+	var cutoff time.Time
+	if maxAge := f.maxAge; maxAge > 0 {
+		cutoff = f.now().Add(-maxAge)
+	}
+
+	for i, backup := range backups {
+		if (f.maxBackups > 0 && i >= int(f.maxBackups)) || backup.created.Before(cutoff) {
+			os.Remove(backup.path)
+			continue
+		}
+		if f.compress && !strings.HasSuffix(backup.path, ".gz") {
+			compressFile(backup.path)
+		}
+	}
+}
+
+type rotatedFile struct {
+	path    string
+	created time.Time
+}
+
+// Rotated files, newest first
+func (f *RotatingFile) backups() []rotatedFile {
+	dir := filepath.Dir(f.path)
+	prefix, ext := f.backupParts()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var backups []rotatedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		ts := strings.TrimSuffix(name[len(prefix):], ".gz")
+		ts, ok := strings.CutSuffix(ts, ext)
+		if !ok {
+			continue
+		}
+
+		created, err := time.Parse(rotatedTimeFormat, ts)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, rotatedFile{path: filepath.Join(dir, name), created: created})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].created.After(backups[j].created)
+	})
+	return backups
+}
+
+func (f *RotatingFile) backupPath(t time.Time) string {
+	prefix, ext := f.backupParts()
+	return filepath.Join(filepath.Dir(f.path), prefix+t.UTC().Format(rotatedTimeFormat)+ext)
+}
+
+// "/var/log/app.log" => "app-", ".log"
+func (f *RotatingFile) backupParts() (string, string) {
+	name := filepath.Base(f.path)
+	ext := filepath.Ext(name)
+	return name[:len(name)-len(ext)] + "-", ext
+}
+
+// Replaces path with a gzipped path.gz
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
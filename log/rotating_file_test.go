@@ -0,0 +1,228 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_RotatingFile_NoRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f := testRotatingFile(t, path, 0, 0, 0, 0, false)
+	f.Write([]byte("line1\n"))
+	f.Write([]byte("line2\n"))
+	assert.Nil(t, f.Close())
+
+	assert.Equal(t, readFile(t, path), "line1\nline2\n")
+	assert.Equal(t, len(listDir(t, filepath.Dir(path))), 1)
+}
+
+func Test_RotatingFile_AppendsToExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	os.WriteFile(path, []byte("before\n"), 0644)
+
+	f := testRotatingFile(t, path, 14, 0, 0, 0, false)
+	f.Write([]byte("line1\n"))
+	f.Write([]byte("line2\n"))
+	assert.Nil(t, f.Close())
+
+	assert.Equal(t, readFile(t, path), "line2\n")
+	assert.Equal(t, readFile(t, filepath.Join(filepath.Dir(path), "app-2023-04-05T06-07-08.000.log")), "before\nline1\n")
+}
+
+func Test_RotatingFile_MaxSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f := testRotatingFile(t, path, 12, 0, 0, 0, false)
+
+	f.Write([]byte("line1\n"))
+	f.Write([]byte("line2\n"))
+	f.Write([]byte("line3\n"))
+
+	// an entry larger than max size is still written, as is
+	f.now = fixedTime(time.Second)
+	f.Write([]byte("a very long line\n"))
+	assert.Nil(t, f.Close())
+
+	assert.Equal(t, readFile(t, path), "a very long line\n")
+	assert.Equal(t, readFile(t, filepath.Join(dir, "app-2023-04-05T06-07-08.000.log")), "line1\nline2\n")
+	assert.Equal(t, readFile(t, filepath.Join(dir, "app-2023-04-05T06-07-09.000.log")), "line3\n")
+}
+
+func Test_RotatingFile_Interval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f := testRotatingFile(t, path, 0, time.Hour, 0, 0, false)
+
+	f.Write([]byte("line1\n"))
+	f.now = fixedTime(59 * time.Minute)
+	f.Write([]byte("line2\n"))
+	f.now = fixedTime(time.Hour)
+	f.Write([]byte("line3\n"))
+	assert.Nil(t, f.Close())
+
+	assert.Equal(t, readFile(t, path), "line3\n")
+	assert.Equal(t, readFile(t, filepath.Join(dir, "app-2023-04-05T07-07-08.000.log")), "line1\nline2\n")
+}
+
+func Test_RotatingFile_MaxBackups_Compress(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f := testRotatingFile(t, path, 6, 0, 0, 2, true)
+
+	for i := 0; i < 5; i++ {
+		f.now = fixedTime(time.Duration(i) * time.Second)
+		f.Write([]byte("line" + strconv.Itoa(i) + "\n"))
+	}
+	assert.Nil(t, f.Close())
+
+	assert.Equal(t, strings.Join(listDir(t, dir), ","), "app-2023-04-05T06-07-11.000.log.gz,app-2023-04-05T06-07-12.000.log.gz,app.log")
+	assert.Equal(t, readFile(t, path), "line4\n")
+	assert.Equal(t, readGzip(t, filepath.Join(dir, "app-2023-04-05T06-07-12.000.log.gz")), "line3\n")
+}
+
+func Test_RotatingFile_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	os.WriteFile(filepath.Join(dir, "app-2023-04-01T00-00-00.000.log"), []byte("old\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "app-2023-04-05T00-00-00.000.log"), []byte("new\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "app-other.log"), []byte("other\n"), 0644)
+
+	f := testRotatingFile(t, path, 6, 0, 48*time.Hour, 0, false)
+	f.Write([]byte("line1\n"))
+	f.Write([]byte("line2\n"))
+	assert.Nil(t, f.Close())
+
+	assert.Equal(t, strings.Join(listDir(t, dir), ","), "app-2023-04-05T00-00-00.000.log,app-2023-04-05T06-07-08.000.log,app-other.log,app.log")
+}
+
+func Test_RotatingFile_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f := testRotatingFile(t, path, 0, 0, 0, 0, false)
+
+	f.Write([]byte("line1\n"))
+	os.Rename(path, filepath.Join(dir, "app.log.1"))
+	f.Write([]byte("line2\n"))
+	assert.Nil(t, f.Reopen())
+	f.Write([]byte("line3\n"))
+	assert.Nil(t, f.Close())
+
+	assert.Equal(t, readFile(t, filepath.Join(dir, "app.log.1")), "line1\nline2\n")
+	assert.Equal(t, readFile(t, path), "line3\n")
+
+	_, err := f.Write([]byte("x\n"))
+	assert.Equal(t, err, os.ErrClosed)
+}
+
+func Test_RotatingFile_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewRotatingFile(filepath.Join(dir, "app.log"), 1000, 0, 0, 0, false)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			line := []byte(strings.Repeat(strconv.Itoa(i), 49) + "\n")
+			for j := 0; j < 100; j++ {
+				f.Write(line)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Nil(t, f.Close())
+
+	lines := 0
+	for _, name := range listDir(t, dir) {
+		for _, line := range strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dir, name))), "\n") {
+			assert.Equal(t, line, strings.Repeat(line[:1], 49))
+			lines++
+		}
+	}
+	assert.Equal(t, lines, 800)
+}
+
+func Test_Configure_Sinks_Rotate(t *testing.T) {
+	defer Configure(Config{})
+	dir := t.TempDir()
+
+	err := Configure(Config{Sinks: []SinkConfig{{Name: "a", Path: "stderr", Rotate: &RotateConfig{}}}})
+	assert.Equal(t, err.Error(), "code: 3009 - log.sinks.a.rotate requires a file path")
+
+	err = Configure(Config{Sinks: []SinkConfig{{Name: "a", Path: filepath.Join(dir, "a.log"), Rotate: &RotateConfig{Interval: "daily"}}}})
+	assert.Equal(t, err.Error(), "code: 3009 - log.sinks.a.rotate.interval is invalid. Should be a duration, such as 24h")
+
+	err = Configure(Config{Sinks: []SinkConfig{{Name: "a", Path: filepath.Join(dir, "a.log"), Rotate: &RotateConfig{MaxAge: "1 week"}}}})
+	assert.Equal(t, err.Error(), "code: 3009 - log.sinks.a.rotate.max_age is invalid. Should be a duration, such as 168h")
+
+	path := filepath.Join(dir, "b.log")
+	err = Configure(Config{
+		Level:          "info",
+		ReopenOnSighup: true,
+		Sinks:          []SinkConfig{{Name: "b", Path: path, Rotate: &RotateConfig{MaxSize: 1, MaxBackups: 1}}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, currentSinks()[0].file.maxSize, 1)
+	assert.Equal(t, currentSinks()[0].file.maxBackups, 1)
+
+	os.Rename(path, path+".1")
+	assert.Nil(t, Reopen())
+	Info("after").Log()
+	assert.StringContains(t, readFile(t, path), "_c=after")
+}
+
+func testRotatingFile(t *testing.T, path string, maxSize uint64, interval time.Duration, maxAge time.Duration, maxBackups uint16, compress bool) *RotatingFile {
+	t.Helper()
+	f, err := NewRotatingFile(path, maxSize, interval, maxAge, maxBackups, compress)
+	assert.Nil(t, err)
+	f.now = fixedTime(0)
+	f.opened = f.now()
+	return f
+}
+
+// 2023-04-05T06:07:08Z + offset
+func fixedTime(offset time.Duration) func() time.Time {
+	t := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC).Add(offset)
+	return func() time.Time { return t }
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	assert.Nil(t, err)
+	data, err := io.ReadAll(gz)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names
+}
@@ -0,0 +1,111 @@
+package log
+
+/*
+Limits how many entries are logged per context (the value passed to
+Info/Warn/Request) per second. The first N entries of each second are
+always logged, after which only 1 in M is logged (or none, if M is 0).
+
+When an entry is logged after others of the same context were dropped,
+it includes a "sampled" field with the number of entries that were dropped
+since the last logged entry, so that the dropped volume stays visible.
+
+The sampler is used by the Pool, which means sampled-out entries return a
+Noop logger without checking a logger out of the pool. Entries logged from a
+logger that's already checked out (e.g. a MultiUse request logger) aren't
+sampled. ERROR and FATAL entries are never sampled.
+*/
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Sampler struct {
+	first      uint64
+	thereafter uint64
+	shards     [16]*samplerShard
+
+	// the current unix time in seconds (swappable for tests)
+	now func() int64
+}
+
+type samplerShard struct {
+	sync.RWMutex
+	lookup map[string]*sampleCounter
+}
+
+type sampleCounter struct {
+	// the unix time (in seconds) that count applies to
+	second atomic.Int64
+
+	// number of entries seen during second
+	count atomic.Uint64
+
+	// number of entries dropped since the last entry was kept
+	dropped atomic.Uint64
+}
+
+func NewSampler(first uint32, thereafter uint32) *Sampler {
+	s := &Sampler{
+		first:      uint64(first),
+		thereafter: uint64(thereafter),
+		now:        unixNow,
+	}
+	for i := 0; i < len(s.shards); i++ {
+		s.shards[i] = &samplerShard{lookup: make(map[string]*sampleCounter)}
+	}
+	return s
+}
+
+// Returns whether an entry for the given context should be logged and, if it
+// should, how many entries for that context were dropped since the last one
+// that was logged.
+func (s *Sampler) Sample(ctx string) (bool, uint64) {
+	c := s.counter(ctx)
+
+	now := s.now()
+	if second := c.second.Load(); second != now && c.second.CompareAndSwap(second, now) {
+		c.count.Store(0)
+	}
+
+	n := c.count.Add(1)
+	if n <= s.first {
+		return true, c.dropped.Swap(0)
+	}
+
+	if thereafter := s.thereafter; thereafter > 0 && (n-s.first)%thereafter == 0 {
+		return true, c.dropped.Swap(0)
+	}
+
+	c.dropped.Add(1)
+	return false, 0
+}
+
+func (s *Sampler) counter(ctx string) *sampleCounter {
+	var h uint32
+	for i := 0; i < len(ctx); i++ {
+		h ^= uint32(ctx[i])
+		h *= 16777619
+	}
+	shard := s.shards[h&15]
+
+	shard.RLock()
+	c, exists := shard.lookup[ctx]
+	shard.RUnlock()
+	if exists {
+		return c
+	}
+
+	shard.Lock()
+	defer shard.Unlock()
+	if c, exists = shard.lookup[ctx]; !exists {
+		c = new(sampleCounter)
+		shard.lookup[ctx] = c
+	}
+	return c
+}
+
+func unixNow() int64 {
+	return time.Now().Unix()
+}
@@ -0,0 +1,102 @@
+package log
+
+import (
+	"strings"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_Sampler_FirstThenThereafter(t *testing.T) {
+	s := testSampler(2, 3)
+
+	assertSample(t, s, "a", true, 0)
+	assertSample(t, s, "a", true, 0)
+	assertSample(t, s, "a", false, 0)
+	assertSample(t, s, "a", false, 0)
+	assertSample(t, s, "a", true, 2)
+	assertSample(t, s, "a", false, 0)
+	assertSample(t, s, "a", false, 0)
+	assertSample(t, s, "a", true, 2)
+
+	// contexts are independent
+	assertSample(t, s, "b", true, 0)
+}
+
+func Test_Sampler_NoThereafter(t *testing.T) {
+	s := testSampler(1, 0)
+	assertSample(t, s, "a", true, 0)
+	for i := 0; i < 10; i++ {
+		assertSample(t, s, "a", false, 0)
+	}
+}
+
+func Test_Sampler_NewSecond(t *testing.T) {
+	s := testSampler(1, 0)
+	assertSample(t, s, "a", true, 0)
+	assertSample(t, s, "a", false, 0)
+	assertSample(t, s, "a", false, 0)
+
+	s.now = func() int64 { return 1001 }
+	assertSample(t, s, "a", true, 2)
+	assertSample(t, s, "a", false, 0)
+}
+
+func Test_Pool_Sampling(t *testing.T) {
+	out := &strings.Builder{}
+	p := NewPool(8, INFO, true, KvFactory(128), nil)
+	p.SetSampler(testSampler(1, 2))
+
+	p.Info("i").LogTo(out)
+	assertKvLog(t, out, true, map[string]string{"_l": "info", "_c": "i"})
+
+	l := p.Info("i")
+	_, ok := l.(Noop)
+	assert.True(t, ok)
+
+	p.Info("i").LogTo(out)
+	assertKvLog(t, out, true, map[string]string{"_l": "info", "_c": "i", "sampled": "1"})
+
+	p.Request("r").LogTo(out)
+	assertKvLog(t, out, true, map[string]string{"_l": "req", "_c": "r"})
+	p.Request("r").LogTo(out)
+	assertKvLog(t, out, true, nil)
+
+	p.Warn("w").LogTo(out)
+	assertKvLog(t, out, true, map[string]string{"_l": "warn", "_c": "w"})
+	p.Warn("w").LogTo(out)
+	assertKvLog(t, out, true, nil)
+
+	// errors and fatals are never sampled
+	for i := 0; i < 5; i++ {
+		p.Error("e").LogTo(out)
+		assertKvLog(t, out, true, map[string]string{"_l": "error", "_c": "e"})
+		p.Fatal("f").LogTo(out)
+		assertKvLog(t, out, true, map[string]string{"_l": "fatal", "_c": "f"})
+	}
+}
+
+func Test_Configure_Sampling(t *testing.T) {
+	err := Configure(Config{Level: "info", Sampling: &SamplingConfig{First: 5, Thereafter: 10}})
+	assert.Nil(t, err)
+	assert.Equal(t, globalPool.sampler.first, 5)
+	assert.Equal(t, globalPool.sampler.thereafter, 10)
+
+	err = Configure(Config{Level: "info"})
+	assert.Nil(t, err)
+	assert.True(t, globalPool.sampler == nil)
+}
+
+func assertSample(t *testing.T, s *Sampler, ctx string, expectedKeep bool, expectedDropped uint64) {
+	t.Helper()
+	keep, dropped := s.Sample(ctx)
+	assert.Equal(t, keep, expectedKeep)
+	assert.Equal(t, dropped, expectedDropped)
+}
+
+// a sampler where time stands still
+func testSampler(first uint32, thereafter uint32) *Sampler {
+	s := NewSampler(first, thereafter)
+	s.now = func() int64 { return 1000 }
+	return s
+}
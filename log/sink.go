@@ -0,0 +1,309 @@
+package log
+
+/*
+Sinks let entries be written to more than one destination. Each sink has a
+minimum level and a filter on request entries. For example, one sink could
+write everything (except request entries) to stderr, a second write only
+ERROR and FATAL entries to a file and a third write only request entries to
+another file.
+
+An entry is rendered once, and the same bytes are written to every sink
+that accepts it. When no sinks are set, entries are written to Out.
+
+Sinks only see entries that pass the logger's own level (and any level
+override), so a sink's level can only further restrict what it receives.
+
+Like level overrides, the sinks are global and swapped atomically. Sinks
+which are replaced are only closed once the entries being written to them
+have been written.
+*/
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"src.goblgobl.com/utils"
+)
+
+type SinkRequests uint8
+
+const (
+	// request entries are written along with other entries
+	SinkRequestsInclude SinkRequests = iota
+
+	// request entries are not written
+	SinkRequestsExclude
+
+	// only request entries are written
+	SinkRequestsOnly
+)
+
+var (
+	// Set by Configure (or SetSinks). When empty, entries are written to Out.
+	sinks atomic.Pointer[sinkSet]
+
+	sighupOnce sync.Once
+)
+
+type Sink struct {
+	name     string
+	out      io.Writer
+	level    Level
+	requests SinkRequests
+
+	// resources created by Configure, which are released when the
+	// sink is replaced
+	file  *RotatingFile
+	async *AsyncWriter
+}
+
+func NewSink(name string, out io.Writer, level Level, requests SinkRequests) *Sink {
+	return &Sink{
+		name:     name,
+		out:      out,
+		level:    level,
+		requests: requests,
+	}
+}
+
+func (s *Sink) Name() string {
+	return s.name
+}
+
+// Request entries are identified by a level of NONE
+func (s *Sink) accepts(level Level) bool {
+	if level == NONE {
+		return s.requests != SinkRequestsExclude
+	}
+	return s.requests != SinkRequestsOnly && level >= s.level
+}
+
+// Flushes and stops the sink's async writer and closes its file, if the
+// sink was created by Configure.
+func (s *Sink) close() {
+	if async := s.async; async != nil {
+		async.Close()
+	}
+	if file := s.file; file != nil {
+		file.Close()
+	}
+}
+
+// Replaces the configured sinks. Sinks created by a previous call to
+// Configure are closed once the new sinks are in place and any entry being
+// written to them is done. No sinks means entries are written to Out.
+func SetSinks(s ...*Sink) {
+	var set *sinkSet
+	if len(s) > 0 {
+		set = &sinkSet{list: s}
+	}
+
+	if previous := sinks.Swap(set); previous != nil {
+		// waits for in-flight writes
+		previous.Lock()
+		previous.closed = true
+		previous.Unlock()
+		for _, sink := range previous.list {
+			sink.close()
+		}
+	}
+}
+
+// The configured sinks (nil when entries are written to Out)
+func currentSinks() sinkList {
+	if s := sinks.Load(); s != nil {
+		return s.list
+	}
+	return nil
+}
+
+// Writes a rendered entry (including its trailing newline) to the configured
+// sinks. Returns false when there are none, in which case the entry should be
+// written to Out.
+func writeSinks(level Level, data []byte) bool {
+	for {
+		set := sinks.Load()
+		if set == nil {
+			return false
+		}
+		set.RLock()
+		if !set.closed {
+			set.list.write(level, data)
+			set.RUnlock()
+			return true
+		}
+		// replaced since we loaded it, the new sinks are already in place
+		set.RUnlock()
+	}
+}
+
+// The sinks set by a call to SetSinks. Entries are written while holding
+// the read lock, so that SetSinks can wait for them before closing the sinks
+// it replaced.
+type sinkSet struct {
+	sync.RWMutex
+	list   sinkList
+	closed bool
+}
+
+type sinkList []*Sink
+
+// Writes a rendered entry (including its trailing newline) to every sink
+// which accepts the entry's level
+func (s sinkList) write(level Level, data []byte) {
+	for _, sink := range s {
+		if sink.accepts(level) {
+			sink.out.Write(data)
+		}
+	}
+}
+
+func (s sinkList) flush() {
+	for _, sink := range s {
+		if async := sink.async; async != nil {
+			async.Flush()
+		}
+	}
+}
+
+// Drains and stops every sink's async writer. Subsequent entries are written
+// directly to the sink's underlying writer.
+func (s sinkList) closeAsync() {
+	for _, sink := range s {
+		if async := sink.async; async != nil {
+			async.Close()
+		}
+	}
+}
+
+// Re-opens every file sink at its configured path (see RotatingFile.Reopen).
+// All sinks are re-opened, the first error (if any) is returned.
+func (s sinkList) reopen() error {
+	var first error
+	for _, sink := range s {
+		if file := sink.file; file != nil {
+			if err := file.Reopen(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// Once started, keeps running for the lifetime of the process (re-opening
+// whatever file sinks are configured at the time of the signal).
+func reopenOnSighup() {
+	sighupOnce.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGHUP)
+		go func() {
+			for range signals {
+				if err := Reopen(); err != nil {
+					Error("log_reopen").Err(err).Log()
+				}
+			}
+		}()
+	})
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// Creates the sinks described by the configuration. Sinks without a path
+// write to out. On error, any file that was opened is closed.
+func configureSinks(configs []SinkConfig, out io.Writer) (sinkList, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	created := make(sinkList, 0, len(configs))
+	for i, config := range configs {
+		sink, err := configureSink(i, config, out)
+		if err != nil {
+			for _, sink := range created {
+				sink.close()
+			}
+			return nil, err
+		}
+		created = append(created, sink)
+	}
+	return created, nil
+}
+
+func configureSink(i int, config SinkConfig, defaultOut io.Writer) (*Sink, error) {
+	name := config.Name
+	if name == "" {
+		return nil, Errf(utils.ERR_INVALID_LOG_SINK, "log.sinks[%d].name is required", i)
+	}
+
+	levelName := config.Level
+	if levelName == "" {
+		levelName = "INFO"
+	}
+	level, ok := parseLevel(levelName)
+	if !ok {
+		return nil, Errf(utils.ERR_INVALID_LOG_SINK, "log.sinks.%s.level is invalid. Should be one of: INFO, WARN, ERROR, FATAL or NONE", name)
+	}
+
+	var requests SinkRequests
+	switch strings.ToUpper(config.Requests) {
+	case "", "INCLUDE":
+		requests = SinkRequestsInclude
+	case "EXCLUDE":
+		requests = SinkRequestsExclude
+	case "ONLY":
+		requests = SinkRequestsOnly
+	default:
+		return nil, Errf(utils.ERR_INVALID_LOG_SINK, "log.sinks.%s.requests is invalid. Should be one of: include, exclude or only", name)
+	}
+
+	var rotate RotateConfig
+	if config.Rotate != nil {
+		rotate = *config.Rotate
+	}
+
+	interval, err := parseDuration(rotate.Interval)
+	if err != nil {
+		return nil, Errf(utils.ERR_INVALID_LOG_SINK, "log.sinks.%s.rotate.interval is invalid. Should be a duration, such as 24h", name)
+	}
+
+	maxAge, err := parseDuration(rotate.MaxAge)
+	if err != nil {
+		return nil, Errf(utils.ERR_INVALID_LOG_SINK, "log.sinks.%s.rotate.max_age is invalid. Should be a duration, such as 168h", name)
+	}
+
+	var out io.Writer
+	var file *RotatingFile
+	switch path := config.Path; path {
+	case "":
+		out = defaultOut
+	case "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		f, err := NewRotatingFile(path, rotate.MaxSize, interval, maxAge, rotate.MaxBackups, rotate.Compress)
+		if err != nil {
+			return nil, Errf(utils.ERR_INVALID_LOG_SINK, "log.sinks.%s.path could not be opened - %w", name, err)
+		}
+		out, file = f, f
+	}
+
+	if config.Rotate != nil && file == nil {
+		return nil, Errf(utils.ERR_INVALID_LOG_SINK, "log.sinks.%s.rotate requires a file path", name)
+	}
+
+	sink := NewSink(name, out, level, requests)
+	sink.file = file
+	return sink, nil
+}
@@ -0,0 +1,184 @@
+package log
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_Sink_Accepts(t *testing.T) {
+	s := NewSink("a", nil, WARN, SinkRequestsInclude)
+	assert.False(t, s.accepts(INFO))
+	assert.True(t, s.accepts(WARN))
+	assert.True(t, s.accepts(FATAL))
+	assert.True(t, s.accepts(NONE))
+
+	s = NewSink("b", nil, INFO, SinkRequestsExclude)
+	assert.True(t, s.accepts(INFO))
+	assert.False(t, s.accepts(NONE))
+
+	s = NewSink("c", nil, INFO, SinkRequestsOnly)
+	assert.False(t, s.accepts(INFO))
+	assert.False(t, s.accepts(FATAL))
+	assert.True(t, s.accepts(NONE))
+}
+
+func Test_SetSinks_Concurrent(t *testing.T) {
+	original := Out
+	Out = io.Discard
+	defer func() { Out = original }()
+	defer SetSinks()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		l := KvFactory(256)(nil, INFO, true)
+		for i := 0; i < 100; i++ {
+			l.Info("i").Log()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			SetSinks(NewSink("a", io.Discard, INFO, SinkRequestsInclude))
+			Flush()
+		}
+	}()
+	wg.Wait()
+	assert.Equal(t, len(currentSinks()), 1)
+}
+
+func Test_SetSinks_WaitsForWrites(t *testing.T) {
+	defer SetSinks()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	file := testRotatingFile(t, path, 0, 0, 0, 0, false)
+	out := &gatedFileWriter{entered: make(chan struct{}), gate: make(chan struct{}), file: file}
+	sink := NewSink("file", out, INFO, SinkRequestsInclude)
+	sink.file = file
+	SetSinks(sink)
+
+	go KvFactory(256)(nil, INFO, true).Info("in_flight").Log()
+	<-out.entered
+
+	replaced := make(chan struct{})
+	go func() {
+		SetSinks()
+		close(replaced)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-replaced:
+		assert.Fail(t, "sinks replaced while an entry was being written")
+	default:
+	}
+
+	close(out.gate)
+	<-replaced
+	assert.Equal(t, KvParse(readFile(t, path))["_c"], "in_flight")
+}
+
+func Test_KvLogger_Sinks(t *testing.T) {
+	all, errors, requests := &strings.Builder{}, &strings.Builder{}, &strings.Builder{}
+	SetSinks(
+		NewSink("all", all, INFO, SinkRequestsExclude),
+		NewSink("errors", errors, ERROR, SinkRequestsExclude),
+		NewSink("requests", requests, INFO, SinkRequestsOnly),
+	)
+	defer SetSinks()
+
+	l := KvFactory(256)(nil, INFO, true)
+	l.Info("i").Log()
+	l.Error("e").Log()
+	l.Request("r").Log()
+
+	lines := strings.Split(strings.TrimSpace(all.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	assert.Equal(t, KvParse(lines[0])["_l"], "info")
+	assert.Equal(t, KvParse(lines[1])["_l"], "error")
+
+	lines = strings.Split(strings.TrimSpace(errors.String()), "\n")
+	assert.Equal(t, len(lines), 1)
+	assert.Equal(t, KvParse(lines[0])["_c"], "e")
+
+	lines = strings.Split(strings.TrimSpace(requests.String()), "\n")
+	assert.Equal(t, len(lines), 1)
+	assert.Equal(t, KvParse(lines[0])["_l"], "req")
+}
+
+func Test_JsonLogger_Sinks(t *testing.T) {
+	all, errors := &strings.Builder{}, &strings.Builder{}
+	SetSinks(
+		NewSink("all", all, INFO, SinkRequestsInclude),
+		NewSink("errors", errors, ERROR, SinkRequestsExclude),
+	)
+	defer SetSinks()
+
+	l := JsonFactory(256)(nil, INFO, true)
+	l.Warn("w").Log()
+	l.Fatal("f").Log()
+
+	assert.Equal(t, strings.Count(all.String(), "\n"), 2)
+	assert.Equal(t, strings.Count(errors.String(), "\n"), 1)
+	assertJsonLog(t, errors, false, map[string]any{"_l": "fatal", "_c": "f"})
+}
+
+func Test_Configure_Sinks_Invalid(t *testing.T) {
+	err := Configure(Config{Sinks: []SinkConfig{{}}})
+	assert.Equal(t, err.Error(), "code: 3009 - log.sinks[0].name is required")
+
+	err = Configure(Config{Sinks: []SinkConfig{{Name: "a", Level: "loud"}}})
+	assert.Equal(t, err.Error(), "code: 3009 - log.sinks.a.level is invalid. Should be one of: INFO, WARN, ERROR, FATAL or NONE")
+
+	err = Configure(Config{Sinks: []SinkConfig{{Name: "a", Requests: "some"}}})
+	assert.Equal(t, err.Error(), "code: 3009 - log.sinks.a.requests is invalid. Should be one of: include, exclude or only")
+
+	err = Configure(Config{Sinks: []SinkConfig{{Name: "a", Path: filepath.Join(t.TempDir(), "missing", "a.log")}}})
+	assert.StringContains(t, err.Error(), "code: 3009 - log.sinks.a.path could not be opened")
+}
+
+func Test_Configure_Sinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.log")
+	err := Configure(Config{
+		Level: "info",
+		Async: &AsyncConfig{},
+		Sinks: []SinkConfig{{Name: "errors", Path: path, Level: "error", Requests: "exclude"}},
+	})
+	assert.Nil(t, err)
+
+	Info("i").Log()
+	Error("e").Log()
+	Request("r").Log()
+	Flush()
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, len(lines), 1)
+	assert.Equal(t, KvParse(lines[0])["_c"], "e")
+
+	// closes the file sink
+	assert.Nil(t, Configure(Config{}))
+	assert.Equal(t, len(currentSinks()), 0)
+}
+
+// Blocks (until gate is closed) before writing to file
+type gatedFileWriter struct {
+	entered chan struct{}
+	gate    chan struct{}
+	file    *RotatingFile
+}
+
+func (w *gatedFileWriter) Write(data []byte) (int, error) {
+	close(w.entered)
+	<-w.gate
+	return w.file.Write(data)
+}
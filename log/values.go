@@ -0,0 +1,64 @@
+package log
+
+/*
+Formatting of the non-string values that the loggers, Field and
+StructuredError support. Values are appended to a caller-provided
+(typically stack-allocated) scratch space to avoid allocations.
+
+None of these produce characters that need escaping in the KV format.
+*/
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+type TimeFormat uint8
+
+const (
+	TimeRFC3339 TimeFormat = iota
+	TimeUnixMs
+)
+
+// How Time values are written, set by Configure
+var timeFormat = TimeRFC3339
+
+func appendFloat(dst []byte, value float64) []byte {
+	return strconv.AppendFloat(dst, value, 'g', -1, 64)
+}
+
+func appendUint64(dst []byte, value uint64) []byte {
+	return strconv.AppendUint(dst, value, 10)
+}
+
+func appendTime(dst []byte, value time.Time) []byte {
+	if timeFormat == TimeUnixMs {
+		return strconv.AppendInt(dst, value.UnixMilli(), 10)
+	}
+	return value.AppendFormat(dst, time.RFC3339)
+}
+
+func appendDuration(dst []byte, value time.Duration) []byte {
+	return append(dst, value.String()...)
+}
+
+// Whether a time value is written as a JSON string (RFC3339) or number (unix ms)
+func timeIsString() bool {
+	return timeFormat != TimeUnixMs
+}
+
+// NaN and +/-Inf aren't valid JSON numbers
+func floatIsNumber(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+func parseTimeFormat(name string) (TimeFormat, bool) {
+	switch name {
+	case "RFC3339":
+		return TimeRFC3339, true
+	case "UNIX_MS":
+		return TimeUnixMs, true
+	}
+	return 0, false
+}
@@ -35,7 +35,11 @@ func New(url string) (DB, error) {
 }
 
 func Scalar[T any](db DB, sql string, args ...any) (T, error) {
-	row := db.Pool.QueryRow(context.Background(), sql, args...)
+	return ScalarContext[T](context.Background(), db, sql, args...)
+}
+
+func ScalarContext[T any](ctx context.Context, db DB, sql string, args ...any) (T, error) {
+	row := db.Pool.QueryRow(ctx, sql, args...)
 
 	var value T
 	err := row.Scan(&value)
@@ -43,13 +47,17 @@ func Scalar[T any](db DB, sql string, args ...any) (T, error) {
 }
 
 func (db DB) TableExists(tableName string) (bool, error) {
+	return db.TableExistsContext(context.Background(), tableName)
+}
+
+func (db DB) TableExistsContext(ctx context.Context, tableName string) (bool, error) {
 	sql := `
 		select exists (
 			select 1 from pg_tables
 			where schemaname = 'public' and tablename = $1
 		)
 	`
-	exists, err := Scalar[bool](db, sql, tableName)
+	exists, err := ScalarContext[bool](ctx, db, sql, tableName)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return false, nil
@@ -60,18 +68,28 @@ func (db DB) TableExists(tableName string) (bool, error) {
 }
 
 func (db DB) Transaction(fn func(tx pgx.Tx) error) error {
-	bg := context.Background()
-	tx, err := db.Pool.Begin(bg)
+	return db.TransactionContext(context.Background(), fn)
+}
+
+// A failure to rollback is logged using the logger in ctx (see log.FromContext)
+func (db DB) TransactionContext(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
 
-	defer tx.Rollback(bg)
+	defer func() {
+		// after a successful commit, this is a noop which returns ErrTxClosed
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.FromContext(ctx).Error("pg_rollback").Err(err).Log()
+		}
+	}()
+
 	if err := fn(tx); err != nil {
 		return err
 	}
 
-	return tx.Commit(bg)
+	return tx.Commit(ctx)
 }
 
 // Exists for our test factory which are designed to work with
@@ -137,7 +155,11 @@ func (db DB) MustExec(sql string, args ...any) {
 }
 
 func (db DB) RowToMap(sql string, args ...any) (typed.Typed, error) {
-	rows, err := db.Query(context.Background(), sql, args...)
+	return db.RowToMapContext(context.Background(), sql, args...)
+}
+
+func (db DB) RowToMapContext(ctx context.Context, sql string, args ...any) (typed.Typed, error) {
+	rows, err := db.Query(ctx, sql, args...)
 	if err != nil {
 		return typed.Typed{}, err
 	}
@@ -154,7 +176,11 @@ func (db DB) RowToMap(sql string, args ...any) (typed.Typed, error) {
 }
 
 func (db DB) RowsToMap(sql string, args ...any) ([]typed.Typed, error) {
-	rows, err := db.Query(context.Background(), sql, args...)
+	return db.RowsToMapContext(context.Background(), sql, args...)
+}
+
+func (db DB) RowsToMapContext(ctx context.Context, sql string, args ...any) ([]typed.Typed, error) {
+	rows, err := db.Query(ctx, sql, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -175,6 +201,16 @@ func (db DB) IsNotFound(err error) bool {
 	return err == ErrNoRows
 }
 
+// Pings the database, for use with http.Health
+func (db DB) HealthCheck(ctx context.Context) error {
+	return db.Ping(ctx)
+}
+
+// Closes the pool (satisfies utils.Releasable, e.g. for http.Serve)
+func (db DB) Release() {
+	db.Close()
+}
+
 // for now, just fix uuids
 func rowToMapTransform(row map[string]any) map[string]any {
 	for key, value := range row {
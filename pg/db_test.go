@@ -128,3 +128,7 @@ func Test_DB_RowsToMap(t *testing.T) {
 	assert.Equal(t, row.String("b"), "bee")
 	assert.Equal(t, row.String("uuid"), "0541242e-de39-426b-8e71-bf12d00035ff")
 }
+
+func Test_HealthCheck(t *testing.T) {
+	assert.Nil(t, db.HealthCheck(context.Background()))
+}
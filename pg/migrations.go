@@ -17,28 +17,34 @@ type Migration struct {
 }
 
 func MigrateAll(db DB, appName string, migrations []Migration) error {
-	latestVersion, err := GetCurrentMigrationVersion(db, appName)
+	return MigrateAllContext(context.Background(), db, appName, migrations)
+}
+
+// Logs using the logger in ctx (see log.FromContext)
+func MigrateAllContext(ctx context.Context, db DB, appName string, migrations []Migration) error {
+	latestVersion, err := GetCurrentMigrationVersionContext(ctx, db, appName)
 	if err != nil {
 		return err
 	}
 
-	log.Info("migration_check_start").String("app", appName).String("storage", "postgres").Int("installed_version", latestVersion).Log()
+	logger := log.FromContext(ctx)
+	logger.Info("migration_check_start").String("app", appName).String("storage", "postgres").Int("installed_version", latestVersion).Log()
 	for _, migration := range migrations {
 		version := int(migration.Version)
 		if version <= latestVersion {
 			continue
 		}
 
-		err := db.Transaction(func(tx pgx.Tx) error {
+		err := db.TransactionContext(ctx, func(tx pgx.Tx) error {
 			if sql := migration.SQL; sql != "" {
-				if _, err := tx.Exec(context.Background(), sql); err != nil {
+				if _, err := tx.Exec(ctx, sql); err != nil {
 					return fmt.Errorf("Failed to run pg migration #%d - %w", version, err)
 				}
 			} else if err := migration.Migrate(tx); err != nil {
 				return fmt.Errorf("Failed to run pg migration #%d - %w", version, err)
 			}
 
-			_, err = tx.Exec(context.Background(), `insert into migrations (app, version) values ($1, $2)`, appName, version)
+			_, err = tx.Exec(ctx, `insert into migrations (app, version) values ($1, $2)`, appName, version)
 
 			if err != nil {
 				return fmt.Errorf("pg insert into migrations - %w", err)
@@ -47,25 +53,29 @@ func MigrateAll(db DB, appName string, migrations []Migration) error {
 		})
 
 		if err != nil {
-			log.Error("migration_fail").Int("version", version).Err(err).Log()
+			logger.Error("migration_fail").Int("version", version).Err(err).Log()
 			return err
 		}
-		log.Info("migration_applied").Int("version", version).Log()
+		logger.Info("migration_applied").Int("version", version).Log()
 	}
 
-	log.Info("migration_check_end").Log()
+	logger.Info("migration_check_end").Log()
 
 	return nil
 }
 
 func GetCurrentMigrationVersion(db DB, appName string) (int, error) {
-	exists, err := db.TableExists("migrations")
+	return GetCurrentMigrationVersionContext(context.Background(), db, appName)
+}
+
+func GetCurrentMigrationVersionContext(ctx context.Context, db DB, appName string) (int, error) {
+	exists, err := db.TableExistsContext(ctx, "migrations")
 	if err != nil {
 		return 0, err
 	}
 
 	if !exists {
-		_, err := db.Exec(context.Background(), `
+		_, err := db.Exec(ctx, `
 			create table migrations (
 				app text not null,
 				version integer not null,
@@ -79,7 +89,7 @@ func GetCurrentMigrationVersion(db DB, appName string) (int, error) {
 		return 0, nil
 	}
 
-	value, err := Scalar[*int](db, `
+	value, err := ScalarContext[*int](ctx, db, `
 		select max(version)
 		from migrations
 		where app = $1
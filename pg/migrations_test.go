@@ -2,11 +2,13 @@ package pg
 
 import (
 	"context"
+	"io"
 	"strings"
 	"testing"
 
 	"github.com/jackc/pgx/v5"
 	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
 )
 
 /*
@@ -102,6 +104,36 @@ func Test_MigrateAll_Error(t *testing.T) {
 	migrateTest("app1") // this should be a noop
 }
 
+func Test_MigrateAllContext_Logger(t *testing.T) {
+	realMigrations := testGetRealMigrations()
+	defer func() {
+		testRestoreRealMigrations(realMigrations)
+	}()
+
+	bg := context.Background()
+	db.Exec(bg, "drop table if exists test_migrations")
+	db.Exec(bg, "drop table if exists migrations")
+
+	out := &strings.Builder{}
+	logger := log.KvFactory(512)(nil, log.INFO, true)
+	logger.Field(log.NewField().String("rid", "r1").Finalize()).MultiUse()
+
+	defer func(original io.Writer) { log.Out = original }(log.Out)
+	log.Out = out
+
+	err := MigrateAllContext(log.WithContext(bg, logger), db, "app1", []Migration{
+		Migration{Version: 1, Migrate: MigrateOne},
+	})
+	assert.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, len(lines), 3)
+	for _, line := range lines {
+		assert.Equal(t, log.KvParse(line)["rid"], "r1")
+	}
+	assert.Equal(t, log.KvParse(lines[1])["_c"], "migration_applied")
+}
+
 func MigrateOne(tx pgx.Tx) error {
 	_, err := tx.Exec(context.Background(), "create table test_migrations (id integer not null)")
 	return err
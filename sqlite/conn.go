@@ -128,6 +128,13 @@ func (c Conn) IsNotFound(err error) bool {
 	return err == ErrNoRows
 }
 
+// Closes the connection (satisfies utils.Releasable, e.g. for http.Serve)
+func (c Conn) Release() {
+	if err := c.Close(); err != nil {
+		log.Error("sqlite_close").Err(err).Log()
+	}
+}
+
 func (c Conn) TableExists(tableName string) (bool, error) {
 	sql := `
 		select exists (
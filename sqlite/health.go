@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"sync"
+
+	"src.goblgobl.com/utils"
+	"src.goblgobl.com/utils/log"
+)
+
+// Runs "select 1" for http.Health, on its own connection. sqlite connections
+// aren't safe for concurrent use, and http.Health runs each check in its own
+// goroutine (which is abandoned, still running, when the check times out),
+// so the check can't use the application's connection. For the same reason,
+// if a previous run is still in progress, the check fails rather than
+// using the connection concurrently.
+type HealthChecker struct {
+	conn Conn
+	lock sync.Mutex
+}
+
+func NewHealthChecker(filePath string) (*HealthChecker, error) {
+	conn, err := New(filePath, false)
+	if err != nil {
+		return nil, err
+	}
+	return &HealthChecker{conn: conn}, nil
+}
+
+// The http.HealthCheck. sqlite doesn't support cancellation, so ctx is
+// ignored.
+func (h *HealthChecker) Check(_ context.Context) error {
+	if !h.lock.TryLock() {
+		return log.Errf(utils.ERR_SQLITE_HEALTH_BUSY, "previous health check is still running")
+	}
+	defer h.lock.Unlock()
+
+	_, err := Scalar[int](h.conn, "select 1")
+	return err
+}
+
+// Closes the connection, once any in-progress check is done (satisfies
+// utils.Releasable, e.g. for http.Serve)
+func (h *HealthChecker) Release() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.conn.Release()
+}
@@ -0,0 +1,29 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_HealthChecker(t *testing.T) {
+	h, err := NewHealthChecker(":memory:")
+	assert.Nil(t, err)
+	defer h.Release()
+
+	assert.Nil(t, h.Check(context.Background()))
+
+	// a run that's still in progress
+	h.lock.Lock()
+	err = h.Check(context.Background())
+	h.lock.Unlock()
+	assert.Equal(t, err.Error(), "code: 3015 - previous health check is still running")
+
+	assert.Nil(t, h.Check(context.Background()))
+}
+
+func Test_HealthChecker_InvalidPath(t *testing.T) {
+	_, err := NewHealthChecker("/tmp/hopefully/does/not/exist")
+	assert.Equal(t, err.Error(), "code: 3004 - file does not exist")
+}
@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"fmt"
 
 	"src.goblgobl.com/utils/log"
@@ -14,12 +15,18 @@ type Migration struct {
 }
 
 func MigrateAll(conn Conn, migrations []Migration) error {
+	return MigrateAllContext(context.Background(), conn, migrations)
+}
+
+// Logs using the logger in ctx (see log.FromContext)
+func MigrateAllContext(ctx context.Context, conn Conn, migrations []Migration) error {
 	latestVersion, err := GetCurrentMigrationVersion(conn)
 	if err != nil {
 		return err
 	}
 
-	log.Info("migration_check_start").String("storage", "sqlite").Int("installed_version", latestVersion).Log()
+	logger := log.FromContext(ctx)
+	logger.Info("migration_check_start").String("storage", "sqlite").Int("installed_version", latestVersion).Log()
 	for _, migration := range migrations {
 		version := int(migration.Version)
 		if version <= latestVersion {
@@ -37,12 +44,12 @@ func MigrateAll(conn Conn, migrations []Migration) error {
 		})
 
 		if err != nil {
-			log.Error("migration_fail").Int("version", version).Err(err).Log()
+			logger.Error("migration_fail").Int("version", version).Err(err).Log()
 			return err
 		}
-		log.Info("migration_applied").Int("version", version).Log()
+		logger.Info("migration_applied").Int("version", version).Log()
 	}
-	log.Info("migration_check_end").Log()
+	logger.Info("migration_check_end").Log()
 
 	return nil
 }
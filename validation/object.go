@@ -1,6 +1,8 @@
 package validation
 
 import (
+	"strconv"
+
 	"src.goblgobl.com/utils/typed"
 )
 
@@ -35,6 +37,50 @@ func (v *ObjectValidator[T]) ValidateInput(input typed.Typed, ctx *Context[T]) b
 	return ctx.IsValid()
 }
 
+// Converts the string values of a flat input (e.g. a query string) into
+// the types which the fields' validators expect: "true" and "false" become
+// bools for Bool fields, numbers become float64s for Float fields, and a
+// single value becomes a one-element []any for Array fields (whose items are
+// converted the same way). Other values are left as-is (the Int validator
+// already accepts strings, and anything which can't be converted will fail
+// validation as usual).
+func (v *ObjectValidator[T]) ConvertStrings(input typed.Typed) {
+	for _, vf := range v.fields {
+		name := vf.field.Name
+		if value, exists := input[name]; exists {
+			input[name] = convertString(value, vf.validator)
+		}
+	}
+}
+
+func convertString[T any](value any, validator Validator[T]) any {
+	switch v := validator.(type) {
+	case *BoolValidator[T]:
+		switch value {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+	case *FloatValidator[T]:
+		if s, ok := value.(string); ok {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f
+			}
+		}
+	case *ArrayValidator[T]:
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for i, item := range values {
+			values[i] = convertString(item, v.validator)
+		}
+		return values
+	}
+	return value
+}
+
 func (v *ObjectValidator[T]) Validate(raw any, ctx *Context[T]) any {
 	if raw == nil {
 		if dflt := v.dflt; dflt != nil {
@@ -22,6 +22,41 @@ func Test_Object_Fields(t *testing.T) {
 		FieldsHaveNoErrors("name", "status")
 }
 
+func Test_Object_ConvertStrings(t *testing.T) {
+	o := Object[E]().
+		Field("name", String[E]()).
+		Field("active", Bool[E]()).
+		Field("price", Float[E]()).
+		Field("tags", Array[E]().Validator(String[E]())).
+		Field("flags", Array[E]().Validator(Bool[E]()))
+
+	input := typed.Typed{
+		"name":   "true",
+		"active": "true",
+		"price":  "1.5",
+		"tags":   "a",
+		"flags":  []any{"false", "true", "x"},
+		"other":  "true",
+	}
+	o.ConvertStrings(input)
+	assert.Equal(t, input.String("name"), "true")
+	assert.Equal(t, input.Bool("active"), true)
+	assert.Equal(t, input.Float("price"), 1.5)
+	assert.List(t, input.Strings("tags"), []string{"a"})
+	flags := input["flags"].([]any)
+	assert.Equal(t, flags[0].(bool), false)
+	assert.Equal(t, flags[1].(bool), true)
+	assert.Equal(t, flags[2].(string), "x")
+	assert.Equal(t, input.String("other"), "true")
+
+	input = typed.Typed{"active": "yes", "price": "cheap"}
+	o.ConvertStrings(input)
+	assert.Equal(t, input.String("active"), "yes")
+	assert.Equal(t, input.String("price"), "cheap")
+	_, exists := input["tags"]
+	assert.False(t, exists)
+}
+
 func Test_Object_Nesting(t *testing.T) {
 	inner := Object[E]().
 		Required().